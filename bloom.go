@@ -59,6 +59,29 @@ func (b *bloomFilter) Contains(h uint64) bool {
 	return true
 }
 
+// resized returns a filter sized for capacity that still contains everything in b.
+// Bit indexes are masked to a power of two, so a smaller filter ORs b's words
+// onto fewer words and a larger one repeats them; either way every bit a lookup
+// of a member probes is set. Keeps b's hash count if it is the lower one, since
+// members were only added with that many bits. Larger filters inherit b's
+// false positive rate until they are next reset.
+func (b *bloomFilter) resized(capacity int, fpRate float64) *bloomFilter {
+	nb := newBloomFilter(capacity, fpRate)
+	nb.k = min(nb.k, b.k)
+	nb.entries = b.entries
+	n := len(nb.data)
+	if n <= len(b.data) {
+		for i, w := range b.data {
+			nb.data[i%n] |= w
+		}
+	} else {
+		for i := range nb.data {
+			nb.data[i] = b.data[i%len(b.data)]
+		}
+	}
+	return nb
+}
+
 func (b *bloomFilter) Reset() {
 	clear(b.data)
 	b.entries = 0
@@ -0,0 +1,385 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// GetMany returns the values found for keys. Missing keys are absent from the map.
+func (c *Cache[K, V]) GetMany(keys []K) map[K]V {
+	found, _ := c.getMany(keys)
+	return found
+}
+
+// SetMany stores several values using the default TTL.
+func (c *Cache[K, V]) SetMany(items map[K]V) {
+	for k, v := range items {
+		c.SetTTL(k, v, c.defaultTTL)
+	}
+}
+
+// FetchMany returns values for keys, calling loader once with every key that is
+// neither cached nor already being loaded by a concurrent Fetch. Keys that other
+// calls are loading are awaited instead. Keys the loader leaves out of its result
+// are missing from the returned map, and a Fetch waiting on one of them gets
+// ErrNotFound. Computed values are stored with the default TTL.
+// With ErrorTTL, errors are cached as Fetch caches them: a loader error for
+// every key loaded, and ErrNotFound for each key the loader left out. Keys with
+// a cached error are not loaded; ErrNotFound leaves them out of the returned
+// map, and any other cached error is returned once the rest are fetched.
+// On error, the returned map holds the values found so far.
+func (c *Cache[K, V]) FetchMany(keys []K, loader func(missing []K) (map[K]V, error)) (map[K]V, error) {
+	found, misses := c.getMany(keys)
+	misses, cached := c.negative.skip(misses)
+	if len(misses) == 0 {
+		return found, cached
+	}
+
+	ctx := context.Background()
+	missing, led, joined := claimFlights(ctx, c.flights, misses)
+	if len(missing) > 0 {
+		vals, err := safeLoad(func() (map[K]V, error) { return loader(missing) })
+		c.memory.stats.recordLoad(err)
+		if err == nil {
+			for _, k := range missing {
+				if v, ok := vals[k]; ok {
+					c.setTTL(k, v, c.defaultTTL)
+					found[k] = v
+				}
+			}
+		}
+		cacheBulkErrors(c.negative, missing, vals, err)
+		finishBulk(c.flights, missing, led, vals, err)
+		if err != nil {
+			repanic(err, c.recoverPanics)
+			return found, err
+		}
+	}
+
+	if err := awaitBulk(ctx, c.flights, joined, found); err != nil {
+		return found, err
+	}
+	return found, cached
+}
+
+// getMany looks up keys in memory, returning the hits and the distinct misses.
+func (c *Cache[K, V]) getMany(keys []K) (found map[K]V, misses []K) {
+	return lookupMany(c.memory, keys)
+}
+
+// lookupMany looks up keys in memory, returning the hits and the distinct misses.
+func lookupMany[K comparable, V any](memory *s3fifo[K, V], keys []K) (found map[K]V, misses []K) {
+	found = make(map[K]V, len(keys))
+	for _, k := range keys {
+		if _, dup := found[k]; dup {
+			continue
+		}
+		v, ok := memory.get(k)
+		memory.stats.recordLookup(ok)
+		if ok {
+			found[k] = v
+		} else {
+			misses = append(misses, k)
+		}
+	}
+	return found, dedupe(misses)
+}
+
+// GetMany returns the values found for keys, checking memory and then persistence.
+// Persistence is queried once for all memory misses if the store implements
+// BatchGetter. Missing keys are absent from the map.
+func (c *TieredCache[K, V]) GetMany(ctx context.Context, keys []K) (map[K]V, error) {
+	found, _, err := c.getMany(ctx, keys)
+	return found, err
+}
+
+// SetMany stores several values to memory, then persistence, using the default TTL.
+// All values are kept in memory even if some persistence writes fail; the
+// failures are joined into the returned error.
+func (c *TieredCache[K, V]) SetMany(ctx context.Context, items map[K]V) error {
+	for k := range items {
+		if err := c.Store.ValidateKey(k); err != nil {
+			return fmt.Errorf("invalid key: %w", err)
+		}
+	}
+
+	expiry := c.memory.calculateExpiry(0, c.defaultTTL)
+	writeThrough := true
+	for k, v := range items {
+		c.memory.stats.recordSet()
+		var err error
+		if writeThrough, err = c.cacheWrite(k, v, timeToNano(expiry)); err != nil {
+			return err
+		}
+	}
+	if !writeThrough {
+		return nil
+	}
+
+	var errs []error
+	for k, v := range items {
+		if err := c.storeSet(ctx, k, v, c.storeExpiry(expiry)); err != nil {
+			c.memory.stats.recordStoreWrite(err)
+			errs = append(errs, fmt.Errorf("persistence store failed for %v: %w", k, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FetchMany returns values for keys from memory, then persistence, then one loader
+// call for every key still missing that is not already being loaded by a
+// concurrent Fetch. Keys that other calls are loading are awaited instead.
+// Keys the loader leaves out of its result are missing from the returned map,
+// and a Fetch waiting on one of them gets ErrNotFound.
+// Computed values are stored with the default TTL.
+// With ErrorTTL, errors are cached in memory as Cache.FetchMany caches them, and
+// keys with a cached error are neither read from persistence nor loaded.
+// On error, the returned map holds the values found so far.
+func (c *TieredCache[K, V]) FetchMany(ctx context.Context, keys []K, loader func(ctx context.Context, missing []K) (map[K]V, error)) (map[K]V, error) {
+	found, misses := lookupMany(c.memory, keys)
+	misses, cached := c.negative.skip(misses)
+	misses, err := c.readMany(ctx, found, misses)
+	if err != nil {
+		return found, err
+	}
+	if len(misses) == 0 {
+		return found, cached
+	}
+
+	missing, led, joined := claimFlights(ctx, c.flights, misses)
+	if len(missing) > 0 {
+		vals, err := safeLoad(func() (map[K]V, error) { return loader(ctx, missing) })
+		c.memory.stats.recordLoad(err)
+		if err == nil {
+			exp := c.memory.calculateExpiry(0, c.defaultTTL)
+			for _, k := range missing {
+				v, ok := vals[k]
+				if !ok {
+					continue
+				}
+				found[k] = v
+				if writeThrough, err := c.cacheWrite(k, v, timeToNano(exp)); !writeThrough || err != nil {
+					continue
+				}
+				if err := c.storeSet(ctx, k, v, c.storeExpiry(exp)); err != nil {
+					c.memory.stats.recordStoreWrite(err)
+					slog.Warn("FetchMany persistence failed", "key", k, "error", err)
+				}
+			}
+		}
+		// A failure caused by the caller giving up says nothing about the keys.
+		if ctx.Err() == nil {
+			cacheBulkErrors(c.negative, missing, vals, err)
+		}
+		finishBulk(c.flights, missing, led, vals, err)
+		if err != nil {
+			repanic(err, c.recoverPanics)
+			return found, err
+		}
+	}
+
+	if err := awaitBulk(ctx, c.flights, joined, found); err != nil {
+		return found, err
+	}
+	return found, cached
+}
+
+// getMany looks up keys in memory, then persistence, returning the hits and
+// the distinct keys found in neither.
+func (c *TieredCache[K, V]) getMany(ctx context.Context, keys []K) (found map[K]V, misses []K, err error) {
+	found, misses = lookupMany(c.memory, keys)
+	misses, err = c.readMany(ctx, found, misses)
+	return found, misses, err
+}
+
+// readMany reads misses from persistence into found, returning the keys it
+// did not find.
+func (c *TieredCache[K, V]) readMany(ctx context.Context, found map[K]V, misses []K) ([]K, error) {
+	if len(misses) == 0 {
+		return nil, nil
+	}
+	for _, k := range misses {
+		if err := c.Store.ValidateKey(k); err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+	}
+
+	vals, err := c.storeGetMany(ctx, misses)
+	if err != nil {
+		return nil, fmt.Errorf("persistence load: %w", err)
+	}
+	remaining := misses[:0]
+	for _, k := range misses {
+		v, ok := vals[k]
+		if !ok {
+			remaining = append(remaining, k)
+			continue
+		}
+		found[k] = v
+	}
+	return remaining, nil
+}
+
+// storeGetMany loads keys from persistence, in one call if the store
+// implements BatchGetter, recording the same stats as storeGet, and caches the
+// values it reads in memory. Reads are shared with concurrent Get, Fetch and
+// batch calls as readStore shares them: keys another call is already reading
+// are awaited rather than read again, and cached by that call.
+func (c *TieredCache[K, V]) storeGetMany(ctx context.Context, keys []K) (map[K]V, error) {
+	vals := make(map[K]V, len(keys))
+	bg, ok := c.Store.(BatchGetter[K, V])
+	if !ok {
+		for _, k := range keys {
+			v, _, found, err := c.readStore(ctx, k)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				vals[k] = v
+			}
+		}
+		return vals, nil
+	}
+
+	// Values still being written back with WriteBack need no store call.
+	unstaged := keys
+	if c.writeBack {
+		unstaged = make([]K, 0, len(keys))
+		for _, k := range keys {
+			if dv, ok := c.memory.staged(k); ok {
+				vals[k] = dv.value
+				c.memory.set(k, dv.value, c.loadedExpiry(c.dirtyExpiry(dv)))
+			} else {
+				unstaged = append(unstaged, k)
+			}
+		}
+	}
+
+	missing, led, joined := claimFlights(ctx, c.reads, unstaged)
+	if len(missing) > 0 {
+		got, _, err := c.leadReads(ctx, bg, missing, led)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(vals, got)
+	}
+	for k, call := range joined {
+		r, err := awaitFlight(ctx, c.reads, k, call)
+		if r.retry && ctx.Err() == nil {
+			r.value, _, r.found, err = c.readStore(ctx, k)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.found {
+			vals[k] = r.value
+		}
+	}
+	return vals, nil
+}
+
+// leadReads reads keys with one GetMany call, caches the values found in memory
+// and publishes each result to the read flight this caller leads for it, as
+// leadRead does for a single key.
+func (c *TieredCache[K, V]) leadReads(ctx context.Context, bg BatchGetter[K, V], keys []K, led []*flightCall[storedValue[V]]) (vals map[K]V, exps map[K]time.Time, err error) {
+	done := false
+	defer func() {
+		retry := !done || err != nil && ctx.Err() != nil
+		for i, k := range keys {
+			v, found := vals[k]
+			finishFlight(c.reads, k, led[i], storedValue[V]{value: v, expiry: exps[k], found: found, retry: retry}, err)
+		}
+	}()
+
+	var start time.Time
+	if c.memory.stats != nil {
+		start = time.Now()
+	}
+	vals, exps, err = bg.GetMany(ctx, keys)
+	c.memory.stats.recordStoreGetMany(start, len(vals), len(keys)-len(vals), err)
+	if err == nil {
+		for k, v := range vals {
+			c.memory.set(k, v, c.loadedExpiry(exps[k]))
+		}
+	}
+	done = true
+	return vals, exps, err
+}
+
+// dedupe removes repeated keys in place, keeping the first occurrence.
+// Hits are already deduplicated through the found map, so only misses need this.
+func dedupe[K comparable](keys []K) []K {
+	if len(keys) < 2 {
+		return keys
+	}
+	seen := make(map[K]struct{}, len(keys))
+	out := keys[:0]
+	for _, k := range keys {
+		if _, dup := seen[k]; !dup {
+			seen[k] = struct{}{}
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// claimFlights starts or joins a flight for each key. It returns the keys whose
+// flights this caller started, with those flights, and the flights it joined.
+func claimFlights[K comparable, V any](ctx context.Context, flights *xsync.Map[K, *flightCall[V]], keys []K) (
+	missing []K, led []*flightCall[V], joined map[K]*flightCall[V],
+) {
+	for _, k := range keys {
+		call, leader := joinFlight(ctx, flights, k, false)
+		if leader {
+			missing = append(missing, k)
+			led = append(led, call)
+			continue
+		}
+		if joined == nil {
+			joined = make(map[K]*flightCall[V])
+		}
+		joined[k] = call
+	}
+	return missing, led, joined
+}
+
+// finishBulk publishes a bulk load to the flights it led. Single-key callers
+// waiting on a key the loader did not return receive ErrNotFound.
+func finishBulk[K comparable, V any](flights *xsync.Map[K, *flightCall[V]], keys []K, led []*flightCall[V], vals map[K]V, err error) {
+	for i, k := range keys {
+		switch v, ok := vals[k]; {
+		case err != nil:
+			var zero V
+			finishFlight(flights, k, led[i], zero, err)
+		case ok:
+			finishFlight(flights, k, led[i], v, nil)
+		default:
+			var zero V
+			finishFlight(flights, k, led[i], zero, ErrNotFound)
+		}
+	}
+}
+
+// awaitBulk waits for joined flights and adds their values to out.
+// Keys whose flight reported ErrNotFound are left out; the first other error is
+// returned once every flight has been waited on, so none is left with a stale waiter.
+func awaitBulk[K comparable, V any](ctx context.Context, flights *xsync.Map[K, *flightCall[V]], joined map[K]*flightCall[V], out map[K]V) error {
+	var first error
+	for k, call := range joined {
+		v, err := awaitFlight(ctx, flights, k, call)
+		switch {
+		case err == nil:
+			out[k] = v
+		case errors.Is(err, ErrNotFound):
+		case first == nil:
+			first = err
+		}
+	}
+	return first
+}
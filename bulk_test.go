@@ -0,0 +1,438 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCache_GetMany_SetMany(t *testing.T) {
+	cache := New[string, int]()
+	cache.SetMany(map[string]int{"a": 1, "b": 2})
+
+	got := cache.GetMany([]string{"a", "b", "c", "a"})
+	if want := map[string]int{"a": 1, "b": 2}; !maps.Equal(got, want) {
+		t.Errorf("GetMany = %v; want %v", got, want)
+	}
+}
+
+func TestCache_FetchMany(t *testing.T) {
+	cache := New[string, int](RecordStats())
+	cache.Set("a", 1)
+
+	var asked []string
+	got, err := cache.FetchMany([]string{"a", "b", "c", "b", "d"}, func(missing []string) (map[string]int, error) {
+		asked = slices.Clone(missing)
+		// "d" does not exist.
+		return map[string]int{"b": 2, "c": 3, "unrequested": 9}, nil
+	})
+	if err != nil {
+		t.Fatalf("FetchMany: %v", err)
+	}
+
+	if want := map[string]int{"a": 1, "b": 2, "c": 3}; !maps.Equal(got, want) {
+		t.Errorf("FetchMany = %v; want %v", got, want)
+	}
+	slices.Sort(asked)
+	if want := []string{"b", "c", "d"}; !slices.Equal(asked, want) {
+		t.Errorf("loader asked for %v; want %v", asked, want)
+	}
+	if v, ok := cache.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %d, %v; want 3, true (loaded values are cached)", v, ok)
+	}
+	if _, ok := cache.Get("unrequested"); ok {
+		t.Error("values for keys nobody asked for should not be cached")
+	}
+	if got := cache.Stats().LoaderCalls; got != 1 {
+		t.Errorf("LoaderCalls = %d; want 1", got)
+	}
+
+	// Everything cached: no loader call.
+	if _, err := cache.FetchMany([]string{"a", "b"}, func([]string) (map[string]int, error) {
+		t.Error("loader should not be called when all keys are cached")
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("FetchMany: %v", err)
+	}
+}
+
+func TestCache_FetchMany_Error(t *testing.T) {
+	cache := New[string, int]()
+	cache.Set("a", 1)
+	boom := errors.New("boom")
+
+	got, err := cache.FetchMany([]string{"a", "b"}, func([]string) (map[string]int, error) { return nil, boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("FetchMany error = %v; want %v", err, boom)
+	}
+	if got["a"] != 1 {
+		t.Errorf("FetchMany on error = %v; want the cached values found so far", got)
+	}
+	if _, ok := cache.flights.Load("b"); ok {
+		t.Error("flight for b should be removed after the loader failed")
+	}
+}
+
+func TestCache_FetchMany_JoinsSingleFetch(t *testing.T) {
+	cache := New[string, int]()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	single := make(chan int, 1)
+	go func() {
+		v, _ := cache.Fetch("a", func() (int, error) { //nolint:errcheck // checked via value
+			close(started)
+			<-release
+			return 1, nil
+		})
+		single <- v
+	}()
+	<-started
+
+	manyDone := make(chan map[string]int, 1)
+	var asked []string
+	go func() {
+		got, _ := cache.FetchMany([]string{"a", "b"}, func(missing []string) (map[string]int, error) { //nolint:errcheck // checked via value
+			asked = slices.Clone(missing)
+			return map[string]int{"b": 2}, nil
+		})
+		manyDone <- got
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	got := <-manyDone
+
+	if want := []string{"b"}; !slices.Equal(asked, want) {
+		t.Errorf("bulk loader asked for %v; want %v (a is already in flight)", asked, want)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !maps.Equal(got, want) {
+		t.Errorf("FetchMany = %v; want %v", got, want)
+	}
+	if v := <-single; v != 1 {
+		t.Errorf("Fetch = %d; want 1", v)
+	}
+}
+
+func TestCache_FetchMany_SingleFetchJoinsBulk(t *testing.T) {
+	cache := New[string, int]()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	manyDone := make(chan struct{})
+	go func() {
+		defer close(manyDone)
+		_, _ = cache.FetchMany([]string{"a", "b"}, func([]string) (map[string]int, error) { //nolint:errcheck // not under test
+			close(started)
+			<-release
+			return map[string]int{"a": 1}, nil
+		})
+	}()
+	<-started
+
+	type result struct {
+		v   int
+		err error
+	}
+	results := make(chan result, 2)
+	for _, k := range []string{"a", "b"} {
+		go func() {
+			v, err := cache.Fetch(k, func() (int, error) { return 0, errors.New("should join the bulk load") })
+			results <- result{v, err}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-manyDone
+
+	var gotA, gotB bool
+	for range 2 {
+		r := <-results
+		switch {
+		case r.err == nil && r.v == 1:
+			gotA = true
+		case errors.Is(r.err, ErrNotFound):
+			gotB = true
+		default:
+			t.Errorf("Fetch = %d, %v", r.v, r.err)
+		}
+	}
+	if !gotA || !gotB {
+		t.Error("single-key Fetch should receive the bulk value, or ErrNotFound for keys the loader left out")
+	}
+}
+
+func TestCache_FetchMany_ErrorTTL(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+	backendErr := errors.New("backend down")
+
+	calls := 0
+	failing := func([]string) (map[string]int, error) {
+		calls++
+		return nil, backendErr
+	}
+	if _, err := cache.FetchMany([]string{"a", "b"}, failing); !errors.Is(err, backendErr) {
+		t.Fatalf("FetchMany = %v; want backendErr", err)
+	}
+	if _, err := cache.FetchMany([]string{"a", "b"}, failing); !errors.Is(err, backendErr) {
+		t.Errorf("second FetchMany = %v; want the cached backendErr", err)
+	}
+	if _, err := cache.Fetch("b", func() (int, error) { calls++; return 0, nil }); !errors.Is(err, backendErr) {
+		t.Errorf("Fetch(b) = %v; want the error cached by FetchMany", err)
+	}
+	if calls != 1 {
+		t.Errorf("loader calls = %d; want 1", calls)
+	}
+
+	// A key the loader leaves out is cached as ErrNotFound.
+	omitting := func(missing []string) (map[string]int, error) {
+		calls++
+		return map[string]int{"c": 3}, nil
+	}
+	got, err := cache.FetchMany([]string{"c", "d"}, omitting)
+	if err != nil || !maps.Equal(got, map[string]int{"c": 3}) {
+		t.Fatalf("FetchMany = %v, %v; want [c:3], nil", got, err)
+	}
+	got, err = cache.FetchMany([]string{"c", "d"}, omitting)
+	if err != nil || !maps.Equal(got, map[string]int{"c": 3}) {
+		t.Errorf("second FetchMany = %v, %v; want [c:3], nil", got, err)
+	}
+	if _, err := cache.Fetch("d", func() (int, error) { calls++; return 4, nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch(d) = %v; want the cached ErrNotFound", err)
+	}
+	if calls != 2 {
+		t.Errorf("loader calls = %d; want 2", calls)
+	}
+}
+
+// batchStore wraps mockStore with a BatchGetter that counts calls.
+type batchStore[K comparable, V any] struct {
+	*mockStore[K, V]
+	calls int
+}
+
+func (s *batchStore[K, V]) GetMany(ctx context.Context, keys []K) (map[K]V, map[K]time.Time, error) {
+	s.calls++
+	vals := make(map[K]V)
+	exps := make(map[K]time.Time)
+	for _, k := range keys {
+		v, exp, found, err := s.Get(ctx, k)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			vals[k], exps[k] = v, exp
+		}
+	}
+	return vals, exps, nil
+}
+
+func TestTieredCache_GetMany_SetMany(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore[string, int]{mockStore: newMockStore[string, int]()}
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := cache.SetMany(ctx, map[string]int{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	if err := store.Set(ctx, "c", 3, time.Time{}); err != nil {
+		t.Fatalf("store.Set: %v", err)
+	}
+
+	got, err := cache.GetMany(ctx, []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if want := map[string]int{"a": 1, "b": 2, "c": 3}; !maps.Equal(got, want) {
+		t.Errorf("GetMany = %v; want %v", got, want)
+	}
+	if store.calls != 1 {
+		t.Errorf("store GetMany calls = %d; want 1", store.calls)
+	}
+	if v, ok := cache.memory.get("c"); !ok || v != 3 {
+		t.Error("values loaded from the store should be cached in memory")
+	}
+}
+
+func TestTieredCache_SetMany_StoreError(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	store.setFailSet(true)
+	if err := cache.SetMany(ctx, map[string]int{"a": 1, "b": 2}); err == nil {
+		t.Error("SetMany should report persistence failures")
+	}
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Len() = %d; want 2 (memory writes succeed regardless)", got)
+	}
+}
+
+func TestTieredCache_FetchMany(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore[string, int]{mockStore: newMockStore[string, int]()}
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	cache.memory.set("mem", 1, 0)
+	if err := store.Set(ctx, "stored", 2, time.Time{}); err != nil {
+		t.Fatalf("store.Set: %v", err)
+	}
+
+	var asked []string
+	got, err := cache.FetchMany(ctx, []string{"mem", "stored", "new", "gone"}, func(_ context.Context, missing []string) (map[string]int, error) {
+		asked = slices.Clone(missing)
+		return map[string]int{"new": 3}, nil
+	})
+	if err != nil {
+		t.Fatalf("FetchMany: %v", err)
+	}
+
+	if want := map[string]int{"mem": 1, "stored": 2, "new": 3}; !maps.Equal(got, want) {
+		t.Errorf("FetchMany = %v; want %v", got, want)
+	}
+	slices.Sort(asked)
+	if want := []string{"gone", "new"}; !slices.Equal(asked, want) {
+		t.Errorf("loader asked for %v; want %v (store is queried first)", asked, want)
+	}
+	if v, _, found, _ := store.Get(ctx, "new"); !found || v != 3 { //nolint:errcheck // mock store
+		t.Error("loaded values should be persisted")
+	}
+}
+
+func TestTieredCache_FetchMany_ErrorTTL(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore[string, int]{mockStore: newMockStore[string, int]()}
+	cache, err := NewTiered[string, int](store, ErrorTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	backendErr := errors.New("backend down")
+	calls := 0
+	loader := func(_ context.Context, missing []string) (map[string]int, error) {
+		calls++
+		if slices.Contains(missing, "a") {
+			return nil, backendErr
+		}
+		return nil, nil
+	}
+	if _, err := cache.FetchMany(ctx, []string{"a"}, loader); !errors.Is(err, backendErr) {
+		t.Fatalf("FetchMany = %v; want backendErr", err)
+	}
+	if _, err := cache.FetchMany(ctx, []string{"b"}, loader); err != nil {
+		t.Fatalf("FetchMany = %v; want nil with b left out", err)
+	}
+	reads := store.calls
+	got, err := cache.FetchMany(ctx, []string{"a", "b"}, loader)
+	if !errors.Is(err, backendErr) || len(got) != 0 {
+		t.Errorf("FetchMany = %v, %v; want no values and the cached backendErr", got, err)
+	}
+	if _, err := cache.Fetch(ctx, "b", func(context.Context) (int, error) { calls++; return 2, nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch(b) = %v; want the cached ErrNotFound", err)
+	}
+	if calls != 2 || store.calls != reads {
+		t.Errorf("loader calls = %d, store reads = %d; want 2 and none for keys with cached errors", calls, store.calls-reads)
+	}
+
+	// A write clears the cached error.
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cache.FetchMany(ctx, []string{"a"}, loader); err != nil || got["a"] != 1 {
+		t.Errorf("FetchMany after Set = %v, %v; want [a:1], nil", got, err)
+	}
+}
+
+func TestTieredCache_FetchMany_SingleFetchJoinsBulk(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	manyDone := make(chan struct{})
+	go func() {
+		defer close(manyDone)
+		_, _ = cache.FetchMany(ctx, []string{"b"}, func(context.Context, []string) (map[string]int, error) { //nolint:errcheck // not under test
+			close(started)
+			<-release
+			return nil, nil
+		})
+	}()
+	<-started
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := cache.Fetch(ctx, "b", func(context.Context) (int, error) { return 0, errors.New("should join the bulk load") })
+		fetched <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-manyDone
+	if err := <-fetched; !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch of a key the bulk loader left out = %v; want ErrNotFound", err)
+	}
+}
+
+func TestTieredCache_FetchMany_FallbackStore(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, RecordStats())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := store.Set(ctx, "stored", 1, time.Time{}); err != nil {
+		t.Fatalf("store.Set: %v", err)
+	}
+	got, err := cache.FetchMany(ctx, []string{"stored", "new"}, func(context.Context, []string) (map[string]int, error) {
+		return map[string]int{"new": 2}, nil
+	})
+	if err != nil {
+		t.Fatalf("FetchMany: %v", err)
+	}
+	if want := map[string]int{"stored": 1, "new": 2}; !maps.Equal(got, want) {
+		t.Errorf("FetchMany = %v; want %v", got, want)
+	}
+	if s := cache.Stats(); s.StoreHits != 1 || s.StoreMisses != 1 {
+		t.Errorf("StoreHits, StoreMisses = %d, %d; want 1, 1", s.StoreHits, s.StoreMisses)
+	}
+}
+
+func TestTieredCache_FetchMany_StoreError(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	store.setFailGet(true)
+	if _, err := cache.FetchMany(ctx, []string{"a"}, func(context.Context, []string) (map[string]int, error) {
+		t.Error("loader should not run when the store fails")
+		return nil, nil
+	}); err == nil {
+		t.Error("FetchMany should fail when the store fails")
+	}
+}
@@ -0,0 +1,54 @@
+package fido
+
+import "time"
+
+// Clock tells the cache what time it is. Every expiry decision reads it: TTLs,
+// ErrorTTL, ExpireAfterAccess, RefreshAfter, Range, Save and Load.
+// Implementations must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock sets the clock used for expiry, such as fidotest.FakeClock to test
+// TTL behavior without sleeping. In a TieredCache it is also handed to stores
+// that have a SetClock method, as the bundled localfs, valkey and datastore do.
+// The ActiveExpiration sweeper still runs once a second of real time, sweeping
+// whatever has expired by the clock. Default: the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) { c.clock = clock }
+}
+
+// now returns the cache's current time.
+func (c *s3fifo[K, V]) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// nowNano returns the cache's current time in the entry expiry representation.
+func (c *s3fifo[K, V]) nowNano() int64 {
+	if c.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return c.clock.Now().UnixNano()
+}
+
+// expired reports whether e has a TTL that has already ended.
+// Only reads the clock for entries that have a TTL.
+func (c *s3fifo[K, V]) expired(e *entry[K, V]) bool {
+	exp := e.expiryNano.Load()
+	return exp != 0 && c.nowNano() > exp
+}
+
+// calculateExpiry returns the expiry time for a given TTL, falling back to defaultTTL.
+// Returns zero Time (no expiry) if both TTL and defaultTTL are zero or negative.
+func (c *s3fifo[K, V]) calculateExpiry(ttl, defaultTTL time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(ttl)
+}
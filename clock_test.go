@@ -0,0 +1,173 @@
+package fido
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/codeGROOVE-dev/fido/fidotest"
+)
+
+// nowNano returns the system time in the entry expiry representation, for
+// tests of caches without WithClock.
+func nowNano() int64 {
+	return time.Now().UnixNano()
+}
+
+func newFakeClock() *fidotest.FakeClock {
+	return fidotest.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestCache_WithClock(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[string, int](WithClock(clock), TTL(time.Minute))
+	cache.Set("a", 1)
+	cache.SetTTL("b", 2, time.Hour)
+
+	clock.Advance(59 * time.Second)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("Get(a) should hit before its TTL")
+	}
+	clock.Advance(2 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get(a) should miss once the clock passes its TTL")
+	}
+	if _, ok := cache.Peek("a"); ok {
+		t.Error("Peek(a) should miss once the clock passes its TTL")
+	}
+	if got := maps.Collect(cache.Range()); !maps.Equal(got, map[string]int{"b": 2}) {
+		t.Errorf("Range() = %v; want only b", got)
+	}
+	if info, ok := cache.GetEntry("b"); !ok || !info.Expiry.Equal(clock.Now().Add(time.Hour-61*time.Second)) {
+		t.Errorf("GetEntry(b).Expiry = %v; want an hour after it was set", info.Expiry)
+	}
+}
+
+func TestCache_WithClock_Shards(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[int, int](WithClock(clock), Shards(2), Size(8192))
+	cache.SetTTL(1, 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+	if _, ok := cache.Get(1); ok {
+		t.Error("Get should miss once the clock passes the TTL")
+	}
+}
+
+func TestCache_WithClock_ErrorTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[string, int](WithClock(clock), ErrorTTL(time.Minute))
+	calls := 0
+	loader := func() (int, error) {
+		calls++
+		return 0, ErrNotFound
+	}
+	cache.Fetch("a", loader) //nolint:errcheck // counting loader calls
+	cache.Fetch("a", loader) //nolint:errcheck // counting loader calls
+	if calls != 1 {
+		t.Fatalf("loader calls = %d; want 1 while the error is cached", calls)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Fetch("a", loader); !errors.Is(err, ErrNotFound) || calls != 2 {
+		t.Errorf("loader calls = %d; want 2 once the cached error expires", calls)
+	}
+}
+
+func TestCache_WithClock_ExpireAfterAccess(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[string, int](WithClock(clock), ExpireAfterAccess(time.Minute))
+	cache.Set("a", 1)
+	for range 5 {
+		clock.Advance(50 * time.Second)
+		if _, ok := cache.Get("a"); !ok {
+			t.Fatal("entry read within its window should stay cached")
+		}
+	}
+	clock.Advance(61 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("entry idle past its window should expire")
+	}
+}
+
+func TestCache_WithClock_RefreshAfter(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[string, int](WithClock(clock), RefreshAfter(time.Minute))
+	cache.Set("a", 1)
+	if cache.memory.claimRefresh("a") {
+		t.Error("fresh entry should not be claimed for refresh")
+	}
+	clock.Advance(2 * time.Minute)
+	if !cache.memory.claimRefresh("a") {
+		t.Error("entry older than RefreshAfter by the clock should be claimed")
+	}
+}
+
+func TestCache_WithClock_ActiveExpiration(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[string, int](WithClock(clock), ActiveExpiration())
+	defer cache.Close()
+	cache.SetTTL("a", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+	cache.memory.sweep(cache.memory.nowNano())
+	if _, ok := cache.memory.getEntry("a"); ok {
+		t.Error("sweep should remove the entry expired by the clock")
+	}
+}
+
+func TestCache_WithClock_SaveLoad(t *testing.T) {
+	clock := newFakeClock()
+	src := New[string, int](WithClock(clock))
+	src.SetTTL("a", 1, time.Minute)
+	src.SetTTL("b", 2, time.Hour)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	dst := New[string, int](WithClock(clock))
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := maps.Collect(dst.Range()); !maps.Equal(got, map[string]int{"b": 2}) {
+		t.Errorf("loaded entries = %v; want only b", got)
+	}
+}
+
+// clockStore is a mockStore that records the clock NewTiered hands it.
+type clockStore[K comparable, V any] struct {
+	*mockStore[K, V]
+	clock interface{ Now() time.Time }
+}
+
+func (s *clockStore[K, V]) SetClock(clock interface{ Now() time.Time }) { s.clock = clock }
+
+func TestTieredCache_WithClock(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	store := &clockStore[string, int]{mockStore: newMockStore[string, int]()}
+	cache, err := NewTiered[string, int](store, WithClock(clock), TTL(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.clock != clock {
+		t.Error("NewTiered should hand the clock to a store with SetClock")
+	}
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	// mockStore.Get checks expiry against the system clock, so read it directly.
+	store.mu.RLock()
+	exp := store.data["a"].expiry
+	store.mu.RUnlock()
+	if want := clock.Now().Add(time.Minute); !exp.Equal(want) {
+		t.Errorf("stored expiry = %v; want %v by the clock", exp, want)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, ok := cache.memory.get("a"); ok {
+		t.Error("memory entry should expire by the clock")
+	}
+}
@@ -0,0 +1,197 @@
+package fido
+
+import "time"
+
+// ComputeOp tells Compute what to do with the value its function returned.
+type ComputeOp uint8
+
+const (
+	// KeepOp leaves the entry as it was, or absent if there was none.
+	KeepOp ComputeOp = iota
+	// SetOp stores the returned value with the default TTL.
+	SetOp
+	// DeleteOp removes the entry.
+	DeleteOp
+)
+
+// Compute atomically updates key based on its current value. fn receives the
+// current value and whether it was found, and its op decides whether the
+// returned value is stored, the entry deleted, or nothing changed.
+// Compute returns the value now held for key and whether there is one.
+//
+// fn runs outside any lock, so it may call back into the cache. If key is written
+// between reading the old value and applying fn's result, fn is called again
+// with the newer value, so it should have no side effects.
+func (c *Cache[K, V]) Compute(key K, fn func(old V, found bool) (V, ComputeOp)) (V, bool) {
+	return c.compute(key, 0, fn)
+}
+
+// compute is Compute storing SetOp results with ttl, or the default TTL if zero.
+func (c *Cache[K, V]) compute(key K, ttl time.Duration, fn func(V, bool) (V, ComputeOp)) (V, bool) {
+	var op ComputeOp
+	val, ok := c.memory.compute(key, timeToNano(c.memory.calculateExpiry(ttl, c.defaultTTL)), func(old V, found bool) (V, ComputeOp) {
+		var v V
+		v, op = fn(old, found)
+		return v, op
+	})
+	switch op {
+	case SetOp:
+		c.memory.stats.recordSet()
+		c.negative.del(key)
+	case DeleteOp:
+		c.memory.stats.recordDelete()
+		c.negative.del(key)
+	default:
+	}
+	return val, ok
+}
+
+// ComputeIfAbsent returns key's value if present. Otherwise it stores and
+// returns the result of fn. loaded reports whether the value was already present.
+// Unlike Fetch, concurrent callers for a missing key may each call fn; one result wins.
+func (c *Cache[K, V]) ComputeIfAbsent(key K, fn func() V) (val V, loaded bool) {
+	val, _ = c.Compute(key, func(old V, found bool) (V, ComputeOp) {
+		loaded = found
+		if found {
+			return old, KeepOp
+		}
+		return fn(), SetOp
+	})
+	return val, loaded
+}
+
+// ComputeIfPresent atomically updates key only if it is present, as Compute does.
+// fn is not called for a missing key. Returns the value now held for key and
+// whether there is one.
+func (c *Cache[K, V]) ComputeIfPresent(key K, fn func(old V) (V, ComputeOp)) (V, bool) {
+	return c.Compute(key, func(old V, found bool) (V, ComputeOp) {
+		if !found {
+			return old, KeepOp
+		}
+		return fn(old)
+	})
+}
+
+// compute applies fn to key's current value. It reads the value and its seqlock
+// sequence, calls fn without holding any lock, and applies the result only if
+// the sequence is unchanged, retrying otherwise. Replacing the value of an
+// existing entry in a count-bounded cache stays lock-free, like setWithHash;
+// inserts, deletes and weighted updates take the mutex.
+func (c *s3fifo[K, V]) compute(key K, expiryNano int64, fn func(V, bool) (V, ComputeOp)) (V, bool) {
+	if c.shards != nil {
+		return c.shard(key).compute(key, expiryNano, fn)
+	}
+	var zero V
+	for {
+		ent, exists := c.entries.Load(key)
+		if exists && ent.onDeathRow() {
+			c.resurrectFromDeathRow(key)
+			continue
+		}
+
+		var old V
+		var seq uint32
+		var expired bool
+		if exists {
+			var ok bool
+			if old, seq, ok = ent.snapshot(); !ok {
+				continue
+			}
+			if expired = c.expired(ent); expired {
+				old = zero
+			}
+		}
+		found := exists && !expired
+
+		val, op := fn(old, found)
+		switch {
+		case op == KeepOp:
+			if found {
+				ent.bumpFreq()
+			}
+			return old, found
+		case op == DeleteOp && !exists:
+			return zero, false
+		case op == SetOp && exists && c.weigher == nil:
+			prev, ok := ent.casValue(seq, val)
+			if !ok {
+				continue
+			}
+			c.setExpiry(key, ent, expiryNano)
+			c.stampRefresh(ent)
+			ent.bumpFreq()
+			if c.onEvict != nil {
+				reason := ReasonReplaced
+				if expired {
+					reason = ReasonExpired
+				}
+				c.onEvict(key, prev, reason)
+			}
+			return val, true
+		}
+
+		var w uint32
+		if op == SetOp && c.weigher != nil {
+			w = c.weigh(key, val)
+		}
+
+		c.mu.Lock()
+		cur, ok := c.entries.Load(key)
+		if ok != exists || (exists && (cur != ent || ent.seq.Load() != seq)) {
+			c.unlock()
+			continue
+		}
+
+		if op == DeleteOp {
+			if expired {
+				c.removeEntry(ent, ReasonExpired)
+			} else {
+				c.removeEntry(ent, ReasonDeleted)
+			}
+			c.unlock()
+			return zero, false
+		}
+
+		if c.weigher != nil {
+			c.setWeightedLocked(key, ent, exists, val, expiryNano, 0, w)
+		} else {
+			c.insert(key, val, expiryNano, 0, 1)
+		}
+		_, stored := c.entries.Load(key)
+		c.unlock()
+		if !stored {
+			return zero, false
+		}
+		return val, true
+	}
+}
+
+// snapshot is loadValue that also returns the sequence the value was read at,
+// for a later casValue.
+func (e *entry[K, V]) snapshot() (V, uint32, bool) {
+	for range 1000 { // bounded retry
+		s1 := e.seq.Load()
+		if s1&1 != 0 {
+			continue
+		}
+		v := e.value
+		if e.seq.Load() == s1 {
+			return v, s1, s1 > 0
+		}
+	}
+	var zero V
+	return zero, 0, false
+}
+
+// casValue stores v only if no write has happened since snapshot returned seq,
+// returning the replaced value.
+func (e *entry[K, V]) casValue(seq uint32, v V) (V, bool) {
+	if !e.seq.CompareAndSwap(seq, seq+1) {
+		var zero V
+		return zero, false
+	}
+	old := e.value
+	e.value = v
+	e.seq.Store(endSeq(seq))
+	return old, true
+}
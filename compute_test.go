@@ -0,0 +1,150 @@
+package fido
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCache_Compute(t *testing.T) {
+	cache := New[string, int](RecordStats())
+
+	incr := func(old int, _ bool) (int, ComputeOp) { return old + 1, SetOp }
+	if v, ok := cache.Compute("n", incr); !ok || v != 1 {
+		t.Errorf("Compute on missing key = %d, %v; want 1, true", v, ok)
+	}
+	if v, ok := cache.Compute("n", incr); !ok || v != 2 {
+		t.Errorf("Compute on existing key = %d, %v; want 2, true", v, ok)
+	}
+
+	v, ok := cache.Compute("n", func(old int, found bool) (int, ComputeOp) {
+		if !found || old != 2 {
+			t.Errorf("fn got %d, %v; want 2, true", old, found)
+		}
+		return 99, KeepOp
+	})
+	if !ok || v != 2 {
+		t.Errorf("Compute with KeepOp = %d, %v; want 2, true", v, ok)
+	}
+
+	if _, ok := cache.Compute("missing", func(int, bool) (int, ComputeOp) { return 1, KeepOp }); ok {
+		t.Error("Compute with KeepOp should not create a missing key")
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("missing key should still be absent")
+	}
+
+	if _, ok := cache.Compute("n", func(int, bool) (int, ComputeOp) { return 0, DeleteOp }); ok {
+		t.Error("Compute with DeleteOp should report the key absent")
+	}
+	if _, ok := cache.Get("n"); ok {
+		t.Error("key should be deleted")
+	}
+
+	st := cache.Stats()
+	if st.Sets != 2 || st.Deletes != 1 {
+		t.Errorf("Sets, Deletes = %d, %d; want 2, 1", st.Sets, st.Deletes)
+	}
+}
+
+func TestCache_Compute_Concurrent(t *testing.T) {
+	cache := New[string, int]()
+
+	const goroutines, iterations = 16, 1000
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for range iterations {
+				cache.Compute("counter", func(old int, _ bool) (int, ComputeOp) { return old + 1, SetOp })
+			}
+		})
+	}
+	wg.Wait()
+
+	if v, _ := cache.Get("counter"); v != goroutines*iterations {
+		t.Errorf("counter = %d; want %d", v, goroutines*iterations)
+	}
+}
+
+func TestCache_Compute_ConcurrentSet(t *testing.T) {
+	// Set writes lock-free; Compute must notice and retry instead of losing it.
+	cache := New[string, int]()
+	cache.Set("k", 0)
+
+	var calls int
+	v, _ := cache.Compute("k", func(old int, _ bool) (int, ComputeOp) {
+		calls++
+		if calls == 1 {
+			cache.Set("k", 10)
+		}
+		return old + 1, SetOp
+	})
+	if v != 11 || calls != 2 {
+		t.Errorf("Compute = %d after %d calls; want 11 after 2", v, calls)
+	}
+}
+
+func TestCache_Compute_Weighted(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(100), Weigher(byteWeigher))
+	cache.Set("a", make([]byte, 10))
+
+	cache.Compute("a", func(old []byte, _ bool) ([]byte, ComputeOp) { return append(old, make([]byte, 30)...), SetOp })
+	if w := cache.memory.totalWeight; w != 40 {
+		t.Errorf("total weight = %d; want 40", w)
+	}
+
+	if _, ok := cache.Compute("a", func([]byte, bool) ([]byte, ComputeOp) { return make([]byte, 101), SetOp }); ok {
+		t.Error("Compute should report an oversized value as not stored")
+	}
+	if cache.memory.totalWeight != 0 {
+		t.Errorf("total weight = %d; want 0", cache.memory.totalWeight)
+	}
+}
+
+func TestCache_Compute_OnEvict(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](OnEvict(log.record))
+	cache.Set("a", 1)
+
+	cache.Compute("a", func(old int, _ bool) (int, ComputeOp) { return old + 1, SetOp })
+	if r := log.last(); r.reason != ReasonReplaced || r.value != 1 {
+		t.Errorf("listener got %v, %d; want replaced, 1", r.reason, r.value)
+	}
+
+	cache.Compute("a", func(int, bool) (int, ComputeOp) { return 0, DeleteOp })
+	if r := log.last(); r.reason != ReasonDeleted || r.value != 2 {
+		t.Errorf("listener got %v, %d; want deleted, 2", r.reason, r.value)
+	}
+}
+
+func TestCache_ComputeIfAbsent(t *testing.T) {
+	cache := New[string, int]()
+
+	v, loaded := cache.ComputeIfAbsent("a", func() int { return 1 })
+	if v != 1 || loaded {
+		t.Errorf("ComputeIfAbsent on missing key = %d, %v; want 1, false", v, loaded)
+	}
+
+	v, loaded = cache.ComputeIfAbsent("a", func() int {
+		t.Error("fn should not be called for a present key")
+		return 2
+	})
+	if v != 1 || !loaded {
+		t.Errorf("ComputeIfAbsent on present key = %d, %v; want 1, true", v, loaded)
+	}
+}
+
+func TestCache_ComputeIfPresent(t *testing.T) {
+	cache := New[string, int]()
+
+	if _, ok := cache.ComputeIfPresent("a", func(int) (int, ComputeOp) {
+		t.Error("fn should not be called for a missing key")
+		return 1, SetOp
+	}); ok {
+		t.Error("ComputeIfPresent should not create a missing key")
+	}
+
+	cache.Set("a", 1)
+	if v, ok := cache.ComputeIfPresent("a", func(old int) (int, ComputeOp) { return old * 10, SetOp }); !ok || v != 10 {
+		t.Errorf("ComputeIfPresent = %d, %v; want 10, true", v, ok)
+	}
+}
@@ -0,0 +1,168 @@
+package fido
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetIfAbsent stores value with the default TTL unless key is already present.
+// It returns the value now held for key, and whether it was already present.
+func (c *Cache[K, V]) SetIfAbsent(key K, value V) (actual V, loaded bool) {
+	return c.SetIfAbsentTTL(key, value, 0)
+}
+
+// SetIfAbsentTTL is like SetIfAbsent but stores value with an explicit TTL.
+func (c *Cache[K, V]) SetIfAbsentTTL(key K, value V, ttl time.Duration) (actual V, loaded bool) {
+	actual, _ = c.compute(key, ttl, ifAbsent(value, &loaded))
+	return actual, loaded
+}
+
+// Replace stores value with the default TTL only if key is present.
+// Returns whether the value was replaced.
+func (c *Cache[K, V]) Replace(key K, value V) bool {
+	return c.ReplaceTTL(key, value, 0)
+}
+
+// ReplaceTTL is like Replace but stores value with an explicit TTL.
+func (c *Cache[K, V]) ReplaceTTL(key K, value V, ttl time.Duration) bool {
+	var replaced bool
+	c.compute(key, ttl, ifPresent(value, &replaced))
+	return replaced
+}
+
+// CompareAndSwap stores newV with the default TTL only if key currently holds old.
+// Returns whether the value was swapped. Values are compared with ==, so V must
+// be comparable at run time; like sync.Map.CompareAndSwap, it panics otherwise.
+func (c *Cache[K, V]) CompareAndSwap(key K, old, newV V) bool {
+	return c.CompareAndSwapTTL(key, old, newV, 0)
+}
+
+// CompareAndSwapTTL is like CompareAndSwap but stores newV with an explicit TTL.
+func (c *Cache[K, V]) CompareAndSwapTTL(key K, old, newV V, ttl time.Duration) bool {
+	var swapped bool
+	c.compute(key, ttl, ifEqual(old, newV, &swapped))
+	return swapped
+}
+
+// SetIfAbsent stores value with the default TTL unless key is already in memory.
+// Persistence is not consulted for the check; a stored value is written through
+// to it like Set. It returns the value now held in memory for key, and whether
+// it was already present.
+//
+//nolint:gocritic // unnamedResult: public API signature is intentionally clear
+func (c *TieredCache[K, V]) SetIfAbsent(ctx context.Context, key K, value V) (V, bool, error) {
+	return c.SetIfAbsentTTL(ctx, key, value, 0)
+}
+
+// SetIfAbsentTTL is like SetIfAbsent but stores value with an explicit TTL.
+//
+//nolint:gocritic // unnamedResult: public API signature is intentionally clear
+func (c *TieredCache[K, V]) SetIfAbsentTTL(ctx context.Context, key K, value V, ttl time.Duration) (V, bool, error) {
+	var loaded bool
+	actual, err := c.setIf(ctx, key, value, ttl, ifAbsent(value, &loaded))
+	return actual, loaded, err
+}
+
+// Replace stores value with the default TTL only if key is in memory, writing
+// it through to persistence like Set. Returns whether the value was replaced.
+func (c *TieredCache[K, V]) Replace(ctx context.Context, key K, value V) (bool, error) {
+	return c.ReplaceTTL(ctx, key, value, 0)
+}
+
+// ReplaceTTL is like Replace but stores value with an explicit TTL.
+func (c *TieredCache[K, V]) ReplaceTTL(ctx context.Context, key K, value V, ttl time.Duration) (bool, error) {
+	var replaced bool
+	_, err := c.setIf(ctx, key, value, ttl, ifPresent(value, &replaced))
+	return replaced, err
+}
+
+// CompareAndSwap stores newV with the default TTL only if key's value in memory
+// is old, writing it through to persistence like Set. Returns whether the value
+// was swapped. Like Cache.CompareAndSwap, it panics if V is not comparable.
+func (c *TieredCache[K, V]) CompareAndSwap(ctx context.Context, key K, old, newV V) (bool, error) {
+	return c.CompareAndSwapTTL(ctx, key, old, newV, 0)
+}
+
+// CompareAndSwapTTL is like CompareAndSwap but stores newV with an explicit TTL.
+func (c *TieredCache[K, V]) CompareAndSwapTTL(ctx context.Context, key K, old, newV V, ttl time.Duration) (bool, error) {
+	var swapped bool
+	_, err := c.setIf(ctx, key, newV, ttl, ifEqual(old, newV, &swapped))
+	return swapped, err
+}
+
+// setIf applies a conditional write to memory and, if fn chose to store value,
+// writes it through to persistence. Returns the value now held in memory.
+func (c *TieredCache[K, V]) setIf(ctx context.Context, key K, value V, ttl time.Duration, fn func(V, bool) (V, ComputeOp)) (V, error) {
+	if err := c.Store.ValidateKey(key); err != nil {
+		var zero V
+		return zero, err
+	}
+
+	if c.writeBack {
+		if !c.beginAsync() {
+			var zero V
+			return zero, ErrClosed
+		}
+		defer c.async.Done()
+	}
+
+	expiry := c.memory.calculateExpiry(ttl, c.defaultTTL)
+	var op ComputeOp
+	actual, _ := c.memory.compute(key, timeToNano(expiry), func(old V, found bool) (V, ComputeOp) {
+		var v V
+		v, op = fn(old, found)
+		return v, op
+	})
+	if op != SetOp {
+		return actual, nil
+	}
+
+	c.memory.stats.recordSet()
+	c.negative.del(key)
+	if c.writeBack {
+		c.markDirty(key, value, timeToNano(expiry))
+		return actual, nil
+	}
+	if err := c.storeSet(ctx, key, value, c.storeExpiry(expiry)); err != nil {
+		c.memory.stats.recordStoreWrite(err)
+		return actual, fmt.Errorf("persistence store failed: %w", err)
+	}
+	return actual, nil
+}
+
+// ifAbsent returns a compute function that stores value only for a missing key,
+// reporting through loaded whether the key was present.
+func ifAbsent[V any](value V, loaded *bool) func(V, bool) (V, ComputeOp) {
+	return func(old V, found bool) (V, ComputeOp) {
+		*loaded = found
+		if found {
+			return old, KeepOp
+		}
+		return value, SetOp
+	}
+}
+
+// ifPresent returns a compute function that stores value only for a present key,
+// reporting through replaced whether it did.
+func ifPresent[V any](value V, replaced *bool) func(V, bool) (V, ComputeOp) {
+	return func(old V, found bool) (V, ComputeOp) {
+		*replaced = found
+		if !found {
+			return old, KeepOp
+		}
+		return value, SetOp
+	}
+}
+
+// ifEqual returns a compute function that stores newV only if the key holds old,
+// reporting through swapped whether it did.
+func ifEqual[V any](old, newV V, swapped *bool) func(V, bool) (V, ComputeOp) {
+	return func(cur V, found bool) (V, ComputeOp) {
+		*swapped = found && any(cur) == any(old)
+		if !*swapped {
+			return cur, KeepOp
+		}
+		return newV, SetOp
+	}
+}
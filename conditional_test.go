@@ -0,0 +1,131 @@
+package fido
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCache_SetIfAbsent(t *testing.T) {
+	cache := New[string, string]()
+
+	if v, loaded := cache.SetIfAbsent("lease", "worker-1"); loaded || v != "worker-1" {
+		t.Errorf("SetIfAbsent on missing key = %q, %v; want worker-1, false", v, loaded)
+	}
+	if v, loaded := cache.SetIfAbsent("lease", "worker-2"); !loaded || v != "worker-1" {
+		t.Errorf("SetIfAbsent on present key = %q, %v; want worker-1, true", v, loaded)
+	}
+}
+
+func TestCache_SetIfAbsentTTL_Expired(t *testing.T) {
+	cache := New[string, string]()
+	cache.memory.set("lease", "old", nowNano()-1)
+
+	if v, loaded := cache.SetIfAbsentTTL("lease", "new", time.Hour); loaded || v != "new" {
+		t.Errorf("SetIfAbsentTTL over expired entry = %q, %v; want new, false", v, loaded)
+	}
+	e, _ := cache.memory.getEntry("lease")
+	if exp := e.expiryNano.Load(); exp < nowNano()+int64(59*time.Minute) {
+		t.Errorf("expiry = %d; want about an hour from now", exp)
+	}
+}
+
+func TestCache_Replace(t *testing.T) {
+	cache := New[string, int]()
+
+	if cache.Replace("a", 1) {
+		t.Error("Replace should fail for a missing key")
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Replace should not create a missing key")
+	}
+
+	cache.Set("a", 1)
+	if !cache.ReplaceTTL("a", 2, time.Minute) {
+		t.Error("ReplaceTTL should succeed for a present key")
+	}
+	if v, _ := cache.Get("a"); v != 2 {
+		t.Errorf("Get(a) = %d; want 2", v)
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	cache := New[string, int](RecordStats())
+	cache.Set("a", 1)
+
+	if cache.CompareAndSwap("a", 5, 6) {
+		t.Error("CompareAndSwap should fail when the old value differs")
+	}
+	if !cache.CompareAndSwap("a", 1, 2) {
+		t.Error("CompareAndSwap should succeed when the old value matches")
+	}
+	if v, _ := cache.Get("a"); v != 2 {
+		t.Errorf("Get(a) = %d; want 2", v)
+	}
+	if cache.CompareAndSwap("missing", 0, 1) {
+		t.Error("CompareAndSwap should fail for a missing key, even against the zero value")
+	}
+	if got := cache.Stats().Sets; got != 2 {
+		t.Errorf("Sets = %d; want 2 (failed swaps are not writes)", got)
+	}
+}
+
+func TestCache_CompareAndSwap_NotComparable(t *testing.T) {
+	cache := New[string, []byte]()
+	cache.Set("a", []byte("x"))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("CompareAndSwap on a non-comparable value type should panic")
+		}
+	}()
+	cache.CompareAndSwap("a", []byte("x"), []byte("y"))
+}
+
+func TestTieredCache_ConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if v, loaded, err := cache.SetIfAbsent(ctx, "a", 1); err != nil || loaded || v != 1 {
+		t.Errorf("SetIfAbsent = %d, %v, %v; want 1, false, nil", v, loaded, err)
+	}
+	if v, loaded, err := cache.SetIfAbsent(ctx, "a", 2); err != nil || !loaded || v != 1 {
+		t.Errorf("SetIfAbsent = %d, %v, %v; want 1, true, nil", v, loaded, err)
+	}
+	if ok, err := cache.Replace(ctx, "b", 1); err != nil || ok {
+		t.Errorf("Replace on missing key = %v, %v; want false, nil", ok, err)
+	}
+	if ok, err := cache.CompareAndSwap(ctx, "a", 1, 3); err != nil || !ok {
+		t.Errorf("CompareAndSwap = %v, %v; want true, nil", ok, err)
+	}
+
+	if v, _, found, _ := store.Get(ctx, "a"); !found || v != 3 { //nolint:errcheck // mock store
+		t.Errorf("store has a=%d, %v; want 3 written through", v, found)
+	}
+	if _, _, found, _ := store.Get(ctx, "b"); found { //nolint:errcheck // mock store
+		t.Error("a failed Replace should not write to the store")
+	}
+}
+
+func TestTieredCache_ConditionalWrites_StoreError(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	store.setFailSet(true)
+	if _, _, err := cache.SetIfAbsent(ctx, "a", 1); err == nil {
+		t.Error("SetIfAbsent should report persistence failures")
+	}
+	if v, ok := cache.memory.get("a"); !ok || v != 1 {
+		t.Error("memory write should succeed even if persistence fails")
+	}
+}
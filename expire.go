@@ -0,0 +1,154 @@
+package fido
+
+import (
+	"sync"
+	"time"
+)
+
+// wheelSlots is the number of one-second slots in the expiry wheel. Entries
+// expiring further out share a slot with nearer ones and stay in it until due,
+// so each is looked at about once per wheelSlots seconds.
+const wheelSlots = 256
+
+// expiryWheel is a hashed timing wheel of keys, slotted by expiry second, that lets
+// the sweeper find expired entries without scanning the whole cache.
+// References are dropped lazily: a key whose entry is gone, or whose expiry moved
+// to another slot, is forgotten the next time its old slot is swept.
+type expiryWheel[K comparable] struct {
+	mu    sync.Mutex
+	slots [wheelSlots]map[K]struct{}
+	next  int64 // first second not yet swept
+}
+
+// wheelSlot returns the slot for an expiry in nanoseconds.
+func wheelSlot(expiryNano int64) int64 {
+	return expiryNano / int64(time.Second) % wheelSlots
+}
+
+func newExpiryWheel[K comparable](now int64) *expiryWheel[K] {
+	w := &expiryWheel[K]{next: now / int64(time.Second)}
+	for i := range w.slots {
+		w.slots[i] = make(map[K]struct{})
+	}
+	return w
+}
+
+// add schedules key for its expiry, which must be non-zero.
+func (w *expiryWheel[K]) add(key K, expiryNano int64) {
+	w.mu.Lock()
+	w.slots[wheelSlot(expiryNano)][key] = struct{}{}
+	w.mu.Unlock()
+}
+
+// reschedule adds key for its new expiry if it moved to another slot.
+// A key that stays in its slot is already scheduled there.
+func (w *expiryWheel[K]) reschedule(key K, old, expiryNano int64) {
+	if expiryNano != 0 && (old == 0 || wheelSlot(old) != wheelSlot(expiryNano)) {
+		w.add(key, expiryNano)
+	}
+}
+
+// due removes and returns the keys whose expiry ended before now, looking up
+// each key's current expiry with expiryOf (0 if the entry is gone). Only whole
+// seconds are swept: entries expiring in the current one wait for the next call.
+func (w *expiryWheel[K]) due(now int64, expiryOf func(K) int64) []K {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sec := now / int64(time.Second)
+	if sec <= w.next {
+		return nil
+	}
+
+	// Entries expiring during second s are expired once that second is over.
+	start := max(w.next, sec-wheelSlots)
+	var keys []K
+	for s := start; s < sec; s++ {
+		slot := s % wheelSlots
+		for k := range w.slots[slot] {
+			exp := expiryOf(k)
+			switch {
+			case exp == 0 || wheelSlot(exp) != slot:
+				delete(w.slots[slot], k)
+			case exp < now:
+				delete(w.slots[slot], k)
+				keys = append(keys, k)
+			}
+		}
+	}
+	w.next = sec
+	return keys
+}
+
+func (w *expiryWheel[K]) flush() {
+	w.mu.Lock()
+	for i := range w.slots {
+		clear(w.slots[i])
+	}
+	w.mu.Unlock()
+}
+
+// setExpiry stores ent's new expiry and schedules it for active expiration.
+func (c *s3fifo[K, V]) setExpiry(key K, ent *entry[K, V], expiryNano int64) {
+	expiryNano = c.resolveExpiry(ent, expiryNano)
+	old := ent.expiryNano.Swap(expiryNano)
+	if c.wheel != nil {
+		c.wheel.reschedule(key, old, expiryNano)
+	}
+}
+
+// sweepLoop removes expired entries once a second until stop is closed.
+func (c *s3fifo[K, V]) sweepLoop(stop <-chan struct{}) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			c.sweep(c.nowNano())
+		}
+	}
+}
+
+// sweep removes entries whose expiry ended before now, notifying the eviction
+// listener with ReasonExpired.
+func (c *s3fifo[K, V]) sweep(now int64) {
+	keys := c.wheel.due(now, func(k K) int64 {
+		if ent, ok := c.entries.Load(k); ok {
+			return ent.expiryNano.Load()
+		}
+		return 0
+	})
+	if len(keys) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	for _, k := range keys {
+		ent, ok := c.entries.Load(k)
+		if !ok {
+			continue
+		}
+		exp := ent.expiryNano.Load()
+		if exp != 0 && exp < now {
+			c.stats.recordRemoval(true)
+			c.removeEntry(ent, ReasonExpired)
+			continue
+		}
+		// Written since due looked at it, possibly without rescheduling.
+		if exp != 0 {
+			c.wheel.add(k, exp)
+		}
+	}
+	c.unlock()
+}
+
+// close stops the sweeper, if running. Safe to call more than once.
+func (c *s3fifo[K, V]) close() {
+	for _, s := range c.shards {
+		s.close()
+	}
+	if c.stopSweep != nil {
+		c.closeOnce.Do(func() { close(c.stopSweep) })
+	}
+}
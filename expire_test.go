@@ -0,0 +1,120 @@
+package fido
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCache_ActiveExpiration_Sweep(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](ActiveExpiration(), OnEvict(log.record), RecordStats())
+	defer cache.Close()
+
+	now, sec := nowNano(), int64(time.Second)
+	cache.memory.set("soon", 1, now+2*sec)
+	cache.memory.set("later", 2, now+(wheelSlots+5)*sec) // shares a slot with nearer expiries
+	cache.memory.set("never", 3, 0)
+
+	cache.memory.sweep(now + 3*sec)
+	if _, ok := cache.memory.entries.Load("soon"); ok {
+		t.Error("expired entry should be removed from the map")
+	}
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Len() = %d; want 2", got)
+	}
+	if r := log.last(); r.key != "soon" || r.reason != ReasonExpired {
+		t.Errorf("listener got %q, %v; want soon, expired", r.key, r.reason)
+	}
+	if got := cache.Stats().Expirations; got != 1 {
+		t.Errorf("Expirations = %d; want 1", got)
+	}
+
+	cache.memory.sweep(now + 10*sec)
+	if _, ok := cache.Get("later"); !ok {
+		t.Error("entry expiring in a later round should survive the sweep of its slot")
+	}
+
+	cache.memory.sweep(now + (wheelSlots+6)*sec)
+	if _, ok := cache.memory.entries.Load("later"); ok {
+		t.Error("entry should be removed once its round comes")
+	}
+	if _, ok := cache.Get("never"); !ok {
+		t.Error("entry without TTL should never be swept")
+	}
+}
+
+func TestCache_ActiveExpiration_Rescheduled(t *testing.T) {
+	cache := New[string, int](ActiveExpiration())
+	defer cache.Close()
+
+	now, sec := nowNano(), int64(time.Second)
+	cache.memory.set("a", 1, now+2*sec)
+	cache.memory.set("a", 2, now+100*sec) // moved to another slot
+	cache.memory.set("b", 1, now+2*sec)
+	cache.memory.set("b", 2, 0) // no longer expires
+
+	cache.memory.sweep(now + 3*sec)
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Len() = %d; want 2 (rewritten entries must not be swept early)", got)
+	}
+
+	cache.memory.sweep(now + 101*sec)
+	if _, ok := cache.memory.entries.Load("a"); ok {
+		t.Error("a should be swept at its new expiry")
+	}
+	if _, ok := cache.memory.entries.Load("b"); !ok {
+		t.Error("b no longer expires and should not be swept")
+	}
+}
+
+func TestCache_ActiveExpiration_Background(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the sweeper")
+	}
+	cache := New[string, int](ActiveExpiration())
+	defer cache.Close()
+
+	cache.SetTTL("a", 1, time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Len() = %d; want 0 once the sweeper ran", got)
+	}
+}
+
+func TestCache_Close_Idempotent(t *testing.T) {
+	cache := New[string, int](ActiveExpiration())
+	cache.Close()
+	cache.Close()
+
+	// Closing only stops the sweeper; the cache keeps working.
+	cache.Set("a", 1)
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("cache should remain usable after Close")
+	}
+
+	New[string, int]().Close() // no sweeper to stop
+}
+
+func TestTieredCache_ActiveExpiration(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int](), ActiveExpiration())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+
+	if err := cache.SetTTL(ctx, "a", 1, time.Second); err != nil {
+		t.Fatalf("SetTTL: %v", err)
+	}
+	cache.memory.sweep(nowNano() + 2*int64(time.Second))
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Len() = %d; want 0", got)
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package fidotest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v; want %v", got, start)
+	}
+	c.Advance(time.Hour)
+	if got := c.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now() after Advance = %v; want %v", got, start.Add(time.Hour))
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set = %v; want %v", got, start)
+	}
+}
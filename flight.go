@@ -0,0 +1,131 @@
+package fido
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// flightCall holds an in-flight computation for singleflight deduplication.
+//
+//nolint:govet // fieldalignment: semantic grouping preferred
+type flightCall[V any] struct {
+	done chan struct{} // closed once val and err are set
+	val  V
+	err  error
+
+	// Only set with CancelAbandonedLoads: the loader's context, its cancel
+	// func, and the number of callers still waiting for the result.
+	ctx     context.Context //nolint:containedctx // owned by the flight, outlives any one caller
+	cancel  context.CancelFunc
+	waiters atomic.Int32
+}
+
+func newFlight[V any]() (*flightCall[V], bool) {
+	return &flightCall[V]{done: make(chan struct{})}, false
+}
+
+// newAbandonableFlight returns a flight whose load runs detached from ctx's
+// cancellation and is cancelled only once every caller waiting on it gives up.
+func newAbandonableFlight[V any](ctx context.Context) *flightCall[V] {
+	fc := &flightCall[V]{done: make(chan struct{})}
+	fc.ctx, fc.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	fc.waiters.Store(1)
+	return fc
+}
+
+// join registers another caller waiting on the result. It fails once every
+// earlier caller has given up, as the load is then being cancelled.
+func (f *flightCall[V]) join() bool {
+	if f.cancel == nil {
+		return true
+	}
+	for {
+		n := f.waiters.Load()
+		if n == 0 {
+			return false
+		}
+		if f.waiters.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// finishFlight removes call from flights, then publishes the result to its waiters.
+func finishFlight[K comparable, V any](flights *xsync.Map[K, *flightCall[V]], key K, call *flightCall[V], val V, err error) {
+	call.val, call.err = val, err
+	removeFlight(flights, key, call)
+	close(call.done)
+	if call.cancel != nil {
+		call.cancel()
+	}
+}
+
+// removeFlight deletes key's flight if it is still call. An abandoned flight
+// is removed early, so a newer one may have taken its place.
+func removeFlight[K comparable, V any](flights *xsync.Map[K, *flightCall[V]], key K, call *flightCall[V]) {
+	if call.cancel == nil {
+		flights.Delete(key)
+		return
+	}
+	flights.Compute(key, func(old *flightCall[V], loaded bool) (*flightCall[V], xsync.ComputeOp) {
+		if loaded && old == call {
+			return nil, xsync.DeleteOp
+		}
+		return old, xsync.CancelOp
+	})
+}
+
+// awaitFlight blocks until call finishes or ctx is done. The last caller to give
+// up on an abandonable flight removes it, so later callers start a fresh load,
+// and cancels its loader.
+func awaitFlight[K comparable, V any](ctx context.Context, flights *xsync.Map[K, *flightCall[V]], key K, call *flightCall[V]) (V, error) {
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+	}
+	if call.cancel != nil && call.waiters.Add(-1) == 0 {
+		removeFlight(flights, key, call)
+		call.cancel()
+	}
+	var zero V
+	return zero, ctx.Err()
+}
+
+// loadFunc is a Fetch loader with or without a context; exactly one is set.
+// Carrying both avoids allocating an adapter closure on every Cache.Fetch.
+type loadFunc[V any] struct {
+	plain   func() (V, error)
+	withCtx func(context.Context) (V, error)
+}
+
+func (l loadFunc[V]) call(ctx context.Context) (V, error) {
+	if l.withCtx != nil {
+		return l.withCtx(ctx)
+	}
+	return l.plain()
+}
+
+// joinFlight returns key's flight, creating it if there is none. leader reports
+// whether this caller created it and must run the load.
+func joinFlight[K comparable, V any](ctx context.Context, flights *xsync.Map[K, *flightCall[V]], key K, abandonable bool) (call *flightCall[V], leader bool) {
+	for {
+		var loaded bool
+		if abandonable {
+			call, loaded = flights.LoadOrCompute(key, func() (*flightCall[V], bool) {
+				return newAbandonableFlight[V](ctx), false
+			})
+		} else {
+			call, loaded = flights.LoadOrCompute(key, newFlight[V])
+		}
+		if !loaded {
+			return call, true
+		}
+		if call.join() {
+			return call, false
+		}
+		// Abandoned and about to be removed; retry until a fresh flight can start.
+	}
+}
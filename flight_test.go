@@ -0,0 +1,410 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestCache_FetchContext(t *testing.T) {
+	cache := New[string, int]()
+	ctx := context.WithValue(context.Background(), ctxKey{}, 42)
+
+	v, err := cache.FetchContext(ctx, "a", func(ctx context.Context) (int, error) {
+		n, _ := ctx.Value(ctxKey{}).(int) //nolint:errcheck // zero on mismatch fails the check below
+		return n, nil
+	})
+	if err != nil || v != 42 {
+		t.Fatalf("FetchContext = %d, %v; want 42, nil", v, err)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 42 {
+		t.Errorf("Get = %d, %v; want 42, true", v, ok)
+	}
+}
+
+// startLeader begins a load for key that blocks until release is closed, and
+// returns once the load is in flight. The result arrives on the returned channel.
+func startLeader(ctx context.Context, t *testing.T, fetch func(context.Context, func(context.Context) (int, error)) (int, error),
+	release <-chan struct{},
+) (loaderCtx context.Context, result <-chan error) {
+	t.Helper()
+	started := make(chan context.Context, 1)
+	done := make(chan error, 1)
+	go func() {
+		_, err := fetch(ctx, func(ctx context.Context) (int, error) {
+			started <- ctx
+			select {
+			case <-release:
+				return 1, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})
+		done <- err
+	}()
+	return <-started, done
+}
+
+func TestCache_FetchContext_WaiterCanceled(t *testing.T) {
+	cache := New[string, int]()
+	release := make(chan struct{})
+	_, leaderDone := startLeader(context.Background(), t, func(ctx context.Context, l func(context.Context) (int, error)) (int, error) {
+		return cache.FetchContext(ctx, "a", l)
+	}, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cache.FetchContext(ctx, "a", func(context.Context) (int, error) {
+		t.Error("waiter should not run its own loader")
+		return 0, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiter error = %v; want context.DeadlineExceeded", err)
+	}
+
+	// The leader is unaffected.
+	close(release)
+	if err := <-leaderDone; err != nil {
+		t.Errorf("leader error = %v; want nil", err)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get = %d, %v; want 1, true", v, ok)
+	}
+}
+
+func TestCache_CancelAbandonedLoads(t *testing.T) {
+	cache := New[string, int](CancelAbandonedLoads())
+	release := make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	lctx, leaderDone := startLeader(leaderCtx, t, func(ctx context.Context, l func(context.Context) (int, error)) (int, error) {
+		return cache.FetchContext(ctx, "a", l)
+	}, release)
+
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	waiterDone := make(chan error, 1)
+	go func() {
+		_, err := cache.FetchContext(waiterCtx, "a", nil)
+		waiterDone <- err
+	}()
+	// Let the waiter join the flight before the leader gives up.
+	time.Sleep(20 * time.Millisecond)
+
+	// The leader giving up does not cancel a load someone else is waiting for.
+	cancelLeader()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader error = %v; want context.Canceled", err)
+	}
+	if lctx.Err() != nil {
+		t.Fatal("loader context canceled while a waiter remains")
+	}
+
+	// Once the last waiter gives up, the loader's context is cancelled.
+	cancelWaiter()
+	if err := <-waiterDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("waiter error = %v; want context.Canceled", err)
+	}
+	select {
+	case <-lctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("loader context not canceled after every caller gave up")
+	}
+
+	// The abandoned failure is not cached: the next caller loads afresh.
+	v, err := cache.FetchContext(context.Background(), "a", func(context.Context) (int, error) { return 2, nil })
+	if err != nil || v != 2 {
+		t.Errorf("FetchContext after abandonment = %d, %v; want 2, nil", v, err)
+	}
+}
+
+func TestCache_CancelAbandonedLoads_Completes(t *testing.T) {
+	cache := New[string, int](CancelAbandonedLoads())
+
+	// A caller that stays to the end gets the result, and the load's context
+	// is released afterwards.
+	var lctx context.Context
+	v, err := cache.FetchContext(context.Background(), "a", func(ctx context.Context) (int, error) {
+		lctx = ctx
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Fatalf("FetchContext = %d, %v; want 1, nil", v, err)
+	}
+	if lctx.Err() == nil {
+		t.Error("loader context should be released once the flight finishes")
+	}
+	if _, ok := cache.flights.Load("a"); ok {
+		t.Error("flight should be removed")
+	}
+}
+
+func TestCache_FetchContext_LeaderContextWithoutOption(t *testing.T) {
+	cache := New[string, int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cache.FetchContext(ctx, "a", func(ctx context.Context) (int, error) { return 0, ctx.Err() })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchContext error = %v; want the leader's context.Canceled", err)
+	}
+}
+
+func TestTieredCache_Fetch_WaiterCanceled(t *testing.T) {
+	cache, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	release := make(chan struct{})
+	_, leaderDone := startLeader(context.Background(), t, func(ctx context.Context, l func(context.Context) (int, error)) (int, error) {
+		return cache.Fetch(ctx, "a", l)
+	}, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Fetch(ctx, "a", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiter error = %v; want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := <-leaderDone; err != nil {
+		t.Errorf("leader error = %v; want nil", err)
+	}
+}
+
+func TestTieredCache_CancelAbandonedLoads(t *testing.T) {
+	cache, err := NewTiered[string, int](newMockStore[string, int](), CancelAbandonedLoads())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lctx, leaderDone := startLeader(ctx, t, func(ctx context.Context, l func(context.Context) (int, error)) (int, error) {
+		return cache.Fetch(ctx, "a", l)
+	}, make(chan struct{}))
+
+	cancel()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader error = %v; want context.Canceled", err)
+	}
+	select {
+	case <-lctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("loader context not canceled after the only caller gave up")
+	}
+}
+
+// slowReadStore is a mockStore whose reads wait until release is closed or
+// their context is done, counting the Get calls that reach it.
+type slowReadStore struct {
+	*mockStore[string, int]
+	started chan struct{}
+	release chan struct{}
+	gets    atomic.Int32
+}
+
+func newSlowReadStore() *slowReadStore {
+	return &slowReadStore{
+		mockStore: newMockStore[string, int](),
+		started:   make(chan struct{}, 16),
+		release:   make(chan struct{}),
+	}
+}
+
+func (s *slowReadStore) wait(ctx context.Context) error {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *slowReadStore) Get(ctx context.Context, key string) (int, time.Time, bool, error) {
+	s.gets.Add(1)
+	if err := s.wait(ctx); err != nil {
+		return 0, time.Time{}, false, err
+	}
+	return s.mockStore.Get(ctx, key)
+}
+
+// slowBatchStore adds a BatchGetter to slowReadStore.
+type slowBatchStore struct {
+	*slowReadStore
+	batches atomic.Int32
+}
+
+func (s *slowBatchStore) GetMany(ctx context.Context, keys []string) (map[string]int, map[string]time.Time, error) {
+	s.batches.Add(1)
+	if err := s.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	vals := make(map[string]int)
+	exps := make(map[string]time.Time)
+	for _, k := range keys {
+		if v, exp, found, _ := s.mockStore.Get(ctx, k); found { //nolint:errcheck // mock
+			vals[k], exps[k] = v, exp
+		}
+	}
+	return vals, exps, nil
+}
+
+func TestTieredCache_Get_SharesStoreRead(t *testing.T) {
+	ctx := context.Background()
+	store := newSlowReadStore()
+	if err := store.Set(ctx, "a", 7, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var replaced atomic.Int32 // memory sets after the first
+	cache, err := NewTiered[string, int](store, countReplaced(&replaced))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	const n = 10
+	results := make(chan int, n+2)
+	for range n {
+		go func() {
+			v, _, err := cache.Get(ctx, "a")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			results <- v
+		}()
+	}
+	go func() {
+		v, err := cache.Fetch(ctx, "a", func(context.Context) (int, error) { return 0, errors.New("loader called") })
+		if err != nil {
+			t.Errorf("Fetch: %v", err)
+		}
+		results <- v
+	}()
+	go func() {
+		vals, err := cache.GetMany(ctx, []string{"a"})
+		if err != nil {
+			t.Errorf("GetMany: %v", err)
+		}
+		results <- vals["a"]
+	}()
+
+	<-store.started
+	time.Sleep(20 * time.Millisecond) // let the other callers join the read
+	close(store.release)
+	for range n + 2 {
+		if v := <-results; v != 7 {
+			t.Errorf("read %d; want 7", v)
+		}
+	}
+	if got := store.gets.Load(); got != 1 {
+		t.Errorf("store Get calls = %d; want 1", got)
+	}
+	if got := replaced.Load(); got != 0 {
+		t.Errorf("value cached %d more times; want only by the read's leader", got)
+	}
+}
+
+// countReplaced counts the memory writes that replace an entry.
+func countReplaced(n *atomic.Int32) Option {
+	return OnEvict(func(_ string, _ int, reason RemovalReason) {
+		if reason == ReasonReplaced {
+			n.Add(1)
+		}
+	})
+}
+
+func TestTieredCache_Get_LeaderCanceled(t *testing.T) {
+	store := newSlowReadStore()
+	if err := store.Set(context.Background(), "a", 7, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, _, err := cache.Get(ctx, "a")
+		leaderDone <- err
+	}()
+	<-store.started
+
+	waiter := make(chan int, 1)
+	go func() {
+		v, _, err := cache.Get(context.Background(), "a")
+		if err != nil {
+			t.Errorf("waiter Get: %v", err)
+		}
+		waiter <- v
+	}()
+	time.Sleep(20 * time.Millisecond) // let the waiter join the read
+	cancel()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader error = %v; want context.Canceled", err)
+	}
+
+	// The waiter reads again rather than fail with the leader's cancellation.
+	<-store.started
+	close(store.release)
+	if v := <-waiter; v != 7 {
+		t.Errorf("waiter Get = %d; want 7", v)
+	}
+}
+
+func TestTieredCache_GetMany_SharesStoreRead(t *testing.T) {
+	ctx := context.Background()
+	store := &slowBatchStore{slowReadStore: newSlowReadStore()}
+	for _, k := range []string{"a", "b"} {
+		if err := store.Set(ctx, k, 1, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaced atomic.Int32
+	cache, err := NewTiered[string, int](store, countReplaced(&replaced))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	batch := make(chan int, 1)
+	go func() {
+		vals, err := cache.GetMany(ctx, []string{"a", "b"})
+		if err != nil {
+			t.Errorf("GetMany: %v", err)
+		}
+		batch <- len(vals)
+	}()
+	<-store.started
+
+	single := make(chan bool, 1)
+	go func() {
+		_, found, err := cache.Get(ctx, "b")
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		single <- found
+	}()
+	time.Sleep(20 * time.Millisecond) // let Get join the batch read
+	close(store.release)
+	if n := <-batch; n != 2 {
+		t.Errorf("GetMany found %d; want 2", n)
+	}
+	if !<-single {
+		t.Error("Get should find b through the batch read")
+	}
+	if gets, batches := store.gets.Load(), store.batches.Load(); gets != 0 || batches != 1 {
+		t.Errorf("store calls = %d Get, %d GetMany; want 0, 1", gets, batches)
+	}
+	if got := replaced.Load(); got != 0 {
+		t.Errorf("b cached %d more times; want only by the batch read", got)
+	}
+}
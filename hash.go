@@ -0,0 +1,134 @@
+package fido
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"reflect"
+	"unsafe"
+)
+
+// maxHashOps bounds the layout plan; larger keys fall back to fmt.
+const maxHashOps = 64
+
+// hashOp hashes one field of a key in place.
+type hashOp struct {
+	offset uintptr
+	size   uintptr
+	kind   reflect.Kind // Uint64 for plain integer bits, Array for raw bytes
+}
+
+// keyHasher returns a zero-allocation hasher for K built from its memory layout,
+// or nil if K holds pointers, interfaces or other values compared by identity
+// or dynamic type, which only fmt can hash.
+// The plan is interpreted rather than composed from closures so the key never
+// escapes to the heap. Struct padding is never read, so equal keys hash equally.
+func keyHasher[K comparable]() func(K) uint64 {
+	ops, ok := appendHashOps(nil, reflect.TypeFor[K](), 0)
+	if !ok || len(ops) > maxHashOps {
+		return nil
+	}
+	return func(k K) uint64 { return hashLayout(unsafe.Pointer(&k), ops) }
+}
+
+// appendHashOps flattens t, found at offset, into ops.
+func appendHashOps(ops []hashOp, t reflect.Type, offset uintptr) ([]hashOp, bool) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(ops, hashOp{offset: offset, size: t.Size(), kind: reflect.Uint64}), true
+	case reflect.Float32, reflect.Float64, reflect.String:
+		return append(ops, hashOp{offset: offset, size: t.Size(), kind: t.Kind()}), true
+	case reflect.Array:
+		if isIntegerKind(t.Elem().Kind()) {
+			// No padding between integers: hash the whole array as bytes.
+			return append(ops, hashOp{offset: offset, size: t.Size(), kind: reflect.Array}), true
+		}
+		for i := range t.Len() {
+			var ok bool
+			if ops, ok = appendHashOps(ops, t.Elem(), offset+uintptr(i)*t.Elem().Size()); !ok || len(ops) > maxHashOps {
+				return nil, false
+			}
+		}
+		return ops, true
+	case reflect.Struct:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.Name == "_" {
+				continue // blank fields are ignored by ==
+			}
+			var ok bool
+			if ops, ok = appendHashOps(ops, f.Type, offset+f.Offset); !ok || len(ops) > maxHashOps {
+				return nil, false
+			}
+		}
+		return ops, true
+	default:
+		return nil, false
+	}
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// hashLayout hashes the value at p following ops.
+func hashLayout(p unsafe.Pointer, ops []hashOp) uint64 {
+	h := uint64(len(ops))
+	for _, op := range ops {
+		q := unsafe.Add(p, op.offset)
+		var v uint64
+		switch op.kind {
+		case reflect.Uint64:
+			switch op.size {
+			case 1:
+				v = uint64(*(*uint8)(q))
+			case 2:
+				v = uint64(*(*uint16)(q))
+			case 4:
+				v = uint64(*(*uint32)(q))
+			default:
+				v = *(*uint64)(q)
+			}
+		case reflect.Float32:
+			// +0 and -0 are equal keys, so they must hash alike.
+			v = uint64(math.Float32bits(*(*float32)(q) + 0))
+		case reflect.Float64:
+			v = math.Float64bits(*(*float64)(q) + 0)
+		case reflect.String:
+			v = hashString(*(*string)(q))
+		default:
+			v = hashBytes(unsafe.Slice((*byte)(q), op.size))
+		}
+		h = mix(h, v)
+	}
+	return h
+}
+
+// hashBytes hashes every byte of b. Unlike hashString, which samples the first
+// and last 8 bytes, it suits fixed-size keys that differ in the middle.
+func hashBytes(b []byte) uint64 {
+	h := uint64(len(b))
+	for len(b) >= 8 {
+		h = mix(h, binary.LittleEndian.Uint64(b))
+		b = b[8:]
+	}
+	if len(b) > 0 {
+		var tail [8]byte
+		copy(tail[:], b)
+		h = mix(h, binary.LittleEndian.Uint64(tail[:]))
+	}
+	return h
+}
+
+// mix folds v into the running hash h (wymix).
+func mix(h, v uint64) uint64 {
+	hi, lo := bits.Mul64(h^wyp0, v^wyp1)
+	return hi ^ lo
+}
@@ -0,0 +1,101 @@
+package fido
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y int16
+	Tag  string
+	_    int32
+	Z    float64
+}
+
+func TestKeyHasher_Distinct(t *testing.T) {
+	distinct := func(t *testing.T, hashes ...uint64) {
+		t.Helper()
+		seen := make(map[uint64]bool)
+		for i, h := range hashes {
+			if seen[h] {
+				t.Errorf("hash %d collides with an earlier key", i)
+			}
+			seen[h] = true
+		}
+	}
+
+	t.Run("int widths", func(t *testing.T) {
+		h8, h16, h32, hu64 := keyHasher[int8](), keyHasher[uint16](), keyHasher[int32](), keyHasher[uint64]()
+		distinct(t, h8(1), h8(2), h8(-1))
+		distinct(t, h16(1), h16(2), h16(math.MaxUint16))
+		distinct(t, h32(1), h32(2), h32(-1))
+		distinct(t, hu64(1), hu64(2), hu64(math.MaxUint64))
+	})
+	t.Run("byte arrays", func(t *testing.T) {
+		h := keyHasher[[20]byte]()
+		var a, b, c [20]byte
+		b[10] = 1 // differs only in the middle
+		c[19] = 1
+		distinct(t, h(a), h(b), h(c))
+	})
+	t.Run("structs", func(t *testing.T) {
+		h := keyHasher[point]()
+		distinct(t, h(point{X: 1}), h(point{Y: 1}), h(point{Tag: "a"}), h(point{Z: 1}), h(point{X: 1, Y: 1}))
+	})
+}
+
+func TestKeyHasher_Equal(t *testing.T) {
+	h := keyHasher[point]()
+	a := point{X: 1, Tag: strings.Repeat("a", 20)}
+	b := point{X: 1, Tag: strings.Repeat("a", 20)} // distinct backing array
+	if h(a) != h(b) {
+		t.Error("equal structs should hash equally")
+	}
+
+	f := keyHasher[float64]()
+	if f(0) != f(math.Copysign(0, -1)) {
+		t.Error("+0 and -0 are equal keys and should hash equally")
+	}
+}
+
+func TestKeyHasher_Unsupported(t *testing.T) {
+	type withPtr struct{ p *int }
+	if keyHasher[*int]() != nil || keyHasher[withPtr]() != nil || keyHasher[any]() != nil {
+		t.Error("keys holding pointers or interfaces should fall back to fmt")
+	}
+}
+
+func TestKeyHasher_NoAllocs(t *testing.T) {
+	h := keyHasher[point]()
+	a := keyHasher[[32]byte]()
+	k := point{X: 1, Tag: "tag"}
+	var b [32]byte
+	if n := testing.AllocsPerRun(100, func() { h(k); a(b) }); n != 0 {
+		t.Errorf("allocs per hash = %v; want 0", n)
+	}
+}
+
+func TestCache_Hasher(t *testing.T) {
+	var calls int
+	cache := New[string, int](Hasher(func(k string) uint64 {
+		calls++
+		return uint64(len(k))
+	}))
+	cache.Set("a", 1)
+	if calls == 0 {
+		t.Error("custom Hasher should be used")
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v; want 1, true", v, ok)
+	}
+}
+
+func TestCache_Hasher_TypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("mismatched Hasher should panic")
+		}
+	}()
+	New[string, int](Hasher(func(int) uint64 { return 0 }))
+}
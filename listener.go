@@ -0,0 +1,88 @@
+package fido
+
+// RemovalReason describes why an entry left the cache.
+type RemovalReason uint8
+
+const (
+	// ReasonEvicted means the entry was evicted to stay within capacity.
+	ReasonEvicted RemovalReason = iota
+	// ReasonExpired means the entry's TTL ended before it was removed.
+	ReasonExpired
+	// ReasonDeleted means the entry was removed by Delete.
+	ReasonDeleted
+	// ReasonFlushed means the entry was removed by Flush.
+	ReasonFlushed
+	// ReasonReplaced means the entry's value was overwritten by a Set.
+	ReasonReplaced
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonFlushed:
+		return "flushed"
+	case ReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// removal is a listener notification queued while s3fifo.mu is held, with the
+// dirty value to write back if its eviction staged one.
+type removal[K comparable, V any] struct {
+	key    K
+	value  V
+	reason RemovalReason
+	staged *dirtyValue[V]
+}
+
+// queueRemoval records e for the eviction listener and the tag index, and
+// stages a dirty entry's value for write-back if it is being evicted. Must be
+// called under mutex, before e is recycled. No-op without a listener, tags or
+// a dirty entry.
+func (c *s3fifo[K, V]) queueRemoval(e *entry[K, V], reason RemovalReason) {
+	c.queueRemovalStaged(e, reason, reason == ReasonEvicted)
+}
+
+// queueRemovalStaged is queueRemoval, staging a dirty entry only if stage is set.
+func (c *s3fifo[K, V]) queueRemovalStaged(e *entry[K, V], reason RemovalReason, stage bool) {
+	c.queueUntag(e.key)
+	var staged *dirtyValue[V]
+	if stage && e.dirty() {
+		staged = c.stageEntry(e)
+	}
+	if c.onEvict == nil && staged == nil {
+		return
+	}
+	v := e.currentValue()
+	c.pending = append(c.pending, removal[K, V]{key: e.key, value: v, reason: reason, staged: staged})
+}
+
+// unlock releases c.mu, then delivers queued removals so a slow listener
+// never holds up inserts.
+func (c *s3fifo[K, V]) unlock() {
+	if c.pending == nil && c.untag == nil {
+		c.mu.Unlock()
+		return
+	}
+	pending, untag := c.pending, c.untag
+	c.pending, c.untag = nil, nil
+	c.mu.Unlock()
+	if untag != nil {
+		c.dropTags(untag)
+	}
+	for _, r := range pending {
+		if r.staged != nil {
+			c.onWriteBack(r.key, r.staged)
+		}
+		if c.onEvict != nil {
+			c.onEvict(r.key, r.value, r.reason)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package fido
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// removalLog records listener calls for assertions.
+type removalLog[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries []removal[K, V]
+}
+
+func (l *removalLog[K, V]) record(k K, v V, r RemovalReason) {
+	l.mu.Lock()
+	l.entries = append(l.entries, removal[K, V]{key: k, value: v, reason: r})
+	l.mu.Unlock()
+}
+
+func (l *removalLog[K, V]) count(r RemovalReason) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.entries {
+		if e.reason == r {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *removalLog[K, V]) last() removal[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries[len(l.entries)-1]
+}
+
+func TestCache_OnEvict_Capacity(t *testing.T) {
+	log := &removalLog[int, int]{}
+	cache := New[int, int](Size(100), OnEvict(log.record))
+
+	for i := range 1000 {
+		cache.Set(i, i*10)
+	}
+
+	// Everything beyond capacity was evicted, except entries parked on death row.
+	want := 1000 - cache.Len() - len(cache.memory.deathRow)
+	if got := log.count(ReasonEvicted); got < want {
+		t.Errorf("evicted notifications = %d; want at least %d", got, want)
+	}
+	for _, r := range log.entries {
+		if r.value != r.key*10 {
+			t.Fatalf("listener got (%d, %d); value does not belong to key", r.key, r.value)
+		}
+	}
+}
+
+func TestCache_OnEvict_Delete(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](OnEvict(log.record))
+
+	cache.Set("a", 1)
+	cache.Delete("a")
+	cache.Delete("missing")
+
+	if got := log.count(ReasonDeleted); got != 1 {
+		t.Fatalf("deleted notifications = %d; want 1", got)
+	}
+	if r := log.last(); r.key != "a" || r.value != 1 {
+		t.Errorf("last removal = %+v; want a=1", r)
+	}
+}
+
+func TestCache_OnEvict_Replace(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](OnEvict(log.record))
+
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+
+	if got := log.count(ReasonReplaced); got != 1 {
+		t.Fatalf("replaced notifications = %d; want 1", got)
+	}
+	if r := log.last(); r.value != 1 {
+		t.Errorf("replaced value = %d; want old value 1", r.value)
+	}
+}
+
+func TestCache_OnEvict_ReplaceExpired(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](OnEvict(log.record))
+
+	cache.memory.set("a", 1, 1) // expired in 1970
+	cache.Set("a", 2)
+
+	if got := log.count(ReasonExpired); got != 1 {
+		t.Errorf("expired notifications = %d; want 1", got)
+	}
+}
+
+func TestCache_OnEvict_EvictExpired(t *testing.T) {
+	log := &removalLog[int, int]{}
+	cache := New[int, int](Size(100), OnEvict(log.record))
+
+	for i := range 100 {
+		cache.memory.set(i, i, 1) // expired in 1970
+	}
+	for i := 100; i < 200; i++ {
+		cache.Set(i, i)
+	}
+
+	if got := log.count(ReasonExpired); got != 100 {
+		t.Errorf("expired notifications = %d; want 100", got)
+	}
+	if got := log.count(ReasonEvicted); got != 0 {
+		t.Errorf("evicted notifications = %d; want 0 (all victims had expired)", got)
+	}
+}
+
+func TestCache_OnEvict_Flush(t *testing.T) {
+	log := &removalLog[string, int]{}
+	cache := New[string, int](OnEvict(log.record))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Flush()
+
+	if got := log.count(ReasonFlushed); got != 2 {
+		t.Errorf("flushed notifications = %d; want 2", got)
+	}
+}
+
+func TestCache_OnEvict_ReentrantListener(t *testing.T) {
+	var cache *Cache[int, int]
+	evicted := &removalLog[int, int]{}
+	cache = New[int, int](Size(100), OnEvict(func(k, v int, r RemovalReason) {
+		// Calling back into the cache would deadlock if the listener ran under the lock.
+		cache.Get(k)
+		cache.Len()
+		evicted.record(k, v, r)
+	}))
+
+	for i := range 500 {
+		cache.Set(i, i)
+	}
+	cache.Delete(499)
+	cache.Flush()
+
+	if evicted.count(ReasonEvicted) == 0 {
+		t.Error("expected evictions")
+	}
+}
+
+func TestCache_OnEvict_TypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New with mismatched OnEvict listener should panic")
+		}
+	}()
+	New[string, int](OnEvict(func(string, string, RemovalReason) {}))
+}
+
+func TestS3FIFO_DeleteFromDeathRow(t *testing.T) {
+	cache := newS3FIFO[int, int](&config{size: 100})
+	for i := range 50 {
+		cache.set(i, i, 0)
+	}
+
+	// Park a hot entry on death row.
+	victim := 7
+	e, _ := cache.getEntry(victim)
+	e.setFreqPeak(0, maxPeakFreq)
+	cache.small.remove(e)
+	cache.sendToDeathRow(e)
+	if !e.onDeathRow() {
+		t.Fatal("entry should be on death row")
+	}
+
+	before := cache.len()
+	smallLen, mainLen := cache.small.len, cache.main.len
+	cache.del(victim)
+
+	if cache.len() != before {
+		t.Errorf("len after deleting death row entry = %d; want %d", cache.len(), before)
+	}
+	if cache.small.len != smallLen || cache.main.len != mainLen {
+		t.Errorf("queue lengths changed: small %d->%d, main %d->%d",
+			smallLen, cache.small.len, mainLen, cache.main.len)
+	}
+	if _, ok := cache.get(victim); ok {
+		t.Error("deleted death row entry should not be resurrected")
+	}
+	for _, e := range cache.deathRow {
+		if e != nil && e.key == victim {
+			t.Error("deleted entry still occupies a death row slot")
+		}
+	}
+}
+
+func TestTieredCache_OnEvict(t *testing.T) {
+	ctx := context.Background()
+	log := &removalLog[string, int]{}
+	cache, err := NewTiered[string, int](newMockStore[string, int](), OnEvict(log.record))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.Set(ctx, "a", 2); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if log.count(ReasonReplaced) != 1 || log.count(ReasonDeleted) != 1 {
+		t.Errorf("removals = %+v; want one replaced and one deleted", log.entries)
+	}
+}
+
+func TestRemovalReason_String(t *testing.T) {
+	tests := map[RemovalReason]string{
+		ReasonEvicted:      "evicted",
+		ReasonExpired:      "expired",
+		ReasonDeleted:      "deleted",
+		ReasonFlushed:      "flushed",
+		ReasonReplaced:     "replaced",
+		RemovalReason(200): "unknown",
+	}
+	for r, want := range tests {
+		if got := r.String(); got != want {
+			t.Errorf("RemovalReason(%d).String() = %q; want %q", r, got, want)
+		}
+	}
+}
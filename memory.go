@@ -2,58 +2,93 @@
 package fido
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"iter"
-	"sync"
+	"log/slog"
+	"runtime/debug"
 	"time"
 
 	"github.com/puzpuzpuz/xsync/v4"
 )
 
-// calculateExpiry returns the expiry time for a given TTL, falling back to defaultTTL.
-// Returns zero Time (no expiry) if both TTL and defaultTTL are zero or negative.
-func calculateExpiry(ttl, defaultTTL time.Duration) time.Time {
-	if ttl <= 0 {
-		ttl = defaultTTL
-	}
-	if ttl <= 0 {
-		return time.Time{}
-	}
-	return time.Now().Add(ttl)
-}
-
 // Cache is an in-memory cache. All operations are synchronous and infallible.
 type Cache[K comparable, V any] struct {
 	flights    *xsync.Map[K, *flightCall[V]]
 	memory     *s3fifo[K, V]
+	negative   *negativeCache[K]
+	codec      Codec
 	defaultTTL time.Duration
+
+	recoverPanics   bool
+	cancelAbandoned bool
 }
 
-// flightCall holds an in-flight computation for singleflight deduplication.
-//
-//nolint:govet // fieldalignment: semantic grouping preferred
-type flightCall[V any] struct {
-	wg  sync.WaitGroup
-	val V
-	err error
+// PanicError is the error Fetch returns when a loader panics.
+// Callers sharing the panicking load all receive it.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("loader panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error) //nolint:errcheck // not an error is a valid outcome
+	return err
+}
+
+// safeLoad calls loader, turning a panic into a *PanicError so that flight
+// waiters are always released.
+func safeLoad[V any](loader func() (V, error)) (val V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return loader()
+}
+
+// repanic re-raises a recovered loader panic in the flight leader, after waiters
+// have been released, unless RecoverPanics was set.
+func repanic(err error, recoverPanics bool) {
+	var pe *PanicError
+	if !recoverPanics && errors.As(err, &pe) {
+		panic(pe)
+	}
 }
 
 // New creates an in-memory cache.
 func New[K comparable, V any](opts ...Option) *Cache[K, V] {
-	cfg := &config{size: 16384}
+	cfg := &config{size: 16384, codec: JSONCodec{}}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	if cfg.expireAfterAccess > 0 {
+		cfg.defaultTTL = 0
+	}
 
 	return &Cache[K, V]{
 		flights:    xsync.NewMap[K, *flightCall[V]](),
 		memory:     newS3FIFO[K, V](cfg),
+		negative:   newNegativeCache[K](cfg),
+		codec:      cfg.codec,
 		defaultTTL: cfg.defaultTTL,
+
+		recoverPanics:   cfg.recoverPanics,
+		cancelAbandoned: cfg.cancelAbandoned,
 	}
 }
 
 // Get returns the value for key, or zero and false if not found.
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	return c.memory.get(key)
+	val, ok := c.memory.get(key)
+	c.memory.stats.recordLookup(ok)
+	return val, ok
 }
 
 // Set stores a value using the default TTL specified at cache creation.
@@ -65,68 +100,150 @@ func (c *Cache[K, V]) Set(key K, value V) {
 // SetTTL stores a value with an explicit TTL.
 // A zero or negative TTL means the entry never expires.
 func (c *Cache[K, V]) SetTTL(key K, value V, ttl time.Duration) {
+	c.memory.stats.recordSet()
+	c.setTTL(key, value, ttl)
+}
+
+func (c *Cache[K, V]) setTTL(key K, value V, ttl time.Duration) {
+	c.negative.del(key)
 	if ttl <= 0 {
 		c.memory.set(key, value, 0)
 		return
 	}
-	//nolint:gosec // G115: Unix seconds fit in uint32 until year 2106
-	c.memory.set(key, value, uint32(time.Now().Add(ttl).Unix()))
+	c.memory.set(key, value, c.memory.now().Add(ttl).UnixNano())
 }
 
 // Delete removes a key from the cache.
 func (c *Cache[K, V]) Delete(key K) {
+	c.memory.stats.recordDelete()
 	c.memory.del(key)
+	c.negative.del(key)
+}
+
+// DeleteFunc removes every entry for which fn returns true, as Delete does,
+// and returns the number removed. fn sees the entries Range yields; entries
+// written during the call may or may not be seen.
+func (c *Cache[K, V]) DeleteFunc(fn func(key K, value V) bool) int {
+	var keys []K
+	for k, v := range c.Range() {
+		if fn(k, v) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		c.Delete(k)
+	}
+	return len(keys)
 }
 
 // Fetch returns cached value or calls loader to compute it.
-// Concurrent calls for the same key share one loader invocation.
+// Concurrent calls for the same key share one loader invocation, as do calls
+// made while FetchMany is loading the key; if its loader leaves the key out of
+// its result, Fetch returns ErrNotFound.
 // Computed values are stored with the default TTL.
+// With ErrorTTL, loader errors are cached and returned until they expire.
 func (c *Cache[K, V]) Fetch(key K, loader func() (V, error)) (V, error) {
-	return c.getSet(key, loader, 0)
+	return c.getSet(context.Background(), key, loadFunc[V]{plain: loader}, 0)
 }
 
 // FetchTTL is like Fetch but stores computed values with an explicit TTL.
 func (c *Cache[K, V]) FetchTTL(key K, ttl time.Duration, loader func() (V, error)) (V, error) {
-	return c.getSet(key, loader, ttl)
+	return c.getSet(context.Background(), key, loadFunc[V]{plain: loader}, ttl)
 }
 
-func (c *Cache[K, V]) getSet(key K, loader func() (V, error), ttl time.Duration) (V, error) {
-	if val, ok := c.memory.get(key); ok {
+// FetchContext is like Fetch but passes ctx to the loader. A caller waiting on
+// another caller's load returns ctx.Err() as soon as ctx is done.
+// With CancelAbandonedLoads, the loader's context is cancelled only once every
+// caller waiting for it has given up.
+func (c *Cache[K, V]) FetchContext(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return c.getSet(ctx, key, loadFunc[V]{withCtx: loader}, 0)
+}
+
+func (c *Cache[K, V]) getSet(ctx context.Context, key K, loader loadFunc[V], ttl time.Duration) (V, error) {
+	val, ok := c.memory.get(key)
+	c.memory.stats.recordLookup(ok)
+	if ok {
+		if c.memory.refreshAfter > 0 && c.memory.claimRefresh(key) {
+			c.refresh(ctx, key, loader, ttl)
+		}
 		return val, nil
 	}
+	if err := c.negative.get(key); err != nil {
+		return val, err
+	}
 
-	call, loaded := c.flights.LoadOrCompute(key, func() (*flightCall[V], bool) {
-		fc := &flightCall[V]{}
-		fc.wg.Add(1)
-		return fc, false
-	})
+	call, leader := joinFlight(ctx, c.flights, key, c.cancelAbandoned)
+	if !leader {
+		return awaitFlight(ctx, c.flights, key, call)
+	}
 
-	if loaded {
-		call.wg.Wait()
-		return call.val, call.err
+	if call.cancel == nil {
+		c.load(ctx, key, call, loader, ttl)
+		val, err := call.val, call.err
+		repanic(err, c.recoverPanics)
+		return val, err
 	}
 
+	// The load must outlive this caller if others are still waiting for it.
+	go c.load(call.ctx, key, call, loader, ttl)
+	val, err := awaitFlight(ctx, c.flights, key, call)
+	repanic(err, c.recoverPanics)
+	return val, err
+}
+
+// load runs a flight this caller leads and publishes the result.
+func (c *Cache[K, V]) load(ctx context.Context, key K, call *flightCall[V], loader loadFunc[V], ttl time.Duration) {
 	if val, ok := c.memory.get(key); ok {
-		call.val = val
-		c.flights.Delete(key)
-		call.wg.Done()
-		return val, nil
+		finishFlight(c.flights, key, call, val, nil)
+		return
+	}
+	if err := c.negative.get(key); err != nil {
+		var zero V
+		finishFlight(c.flights, key, call, zero, err)
+		return
 	}
 
-	val, err := loader()
-	if err == nil {
+	val, err := safeLoad(func() (V, error) { return loader.call(ctx) })
+	c.memory.stats.recordLoad(err)
+	switch {
+	case err == nil:
 		if ttl <= 0 {
-			c.Set(key, val)
-		} else {
-			c.SetTTL(key, val, ttl)
+			ttl = c.defaultTTL
 		}
+		c.setTTL(key, val, ttl)
+	case ctx.Err() == nil:
+		// A failure caused by the caller giving up says nothing about the key.
+		c.negative.set(key, err)
 	}
 
-	call.val, call.err = val, err
-	c.flights.Delete(key)
-	call.wg.Done()
+	finishFlight(c.flights, key, call, val, err)
+}
 
-	return val, err
+// refresh reloads key in the background, keeping the cached value if the loader fails.
+// The reload is registered in flights, so a Fetch that misses meanwhile waits for it
+// instead of starting another, and a reload already in flight is not duplicated.
+// The reload outlives the caller, so it runs with ctx's values but not its cancellation.
+func (c *Cache[K, V]) refresh(ctx context.Context, key K, loader loadFunc[V], ttl time.Duration) {
+	call, loaded := c.flights.LoadOrCompute(key, newFlight[V])
+	if loaded {
+		return
+	}
+
+	// Nobody can catch a panic in the background, so it is only logged.
+	go func() {
+		val, err := safeLoad(func() (V, error) { return loader.call(context.WithoutCancel(ctx)) })
+		c.memory.stats.recordLoad(err)
+		if err == nil {
+			if ttl <= 0 {
+				ttl = c.defaultTTL
+			}
+			c.setTTL(key, val, ttl)
+		} else {
+			slog.Warn("background refresh failed", "key", key, "error", err)
+		}
+
+		finishFlight(c.flights, key, call, val, err)
+	}()
 }
 
 // Len returns the number of entries.
@@ -134,21 +251,34 @@ func (c *Cache[K, V]) Len() int {
 	return c.memory.len()
 }
 
+// Stats returns a snapshot of the cache counters.
+// All counters are zero unless the cache was created with RecordStats.
+func (c *Cache[K, V]) Stats() Stats {
+	return c.memory.stats.snapshot()
+}
+
 // Flush removes all entries. Returns count removed.
 func (c *Cache[K, V]) Flush() int {
+	c.negative.flush()
+	c.memory.tags.clear()
 	return c.memory.flush()
 }
 
+// Close stops the ActiveExpiration goroutine, if any. The cache remains usable,
+// but expired entries are no longer removed in the background.
+func (c *Cache[K, V]) Close() {
+	c.memory.close()
+}
+
 // Range returns an iterator over all non-expired key-value pairs.
 // Iteration order is undefined. Safe for concurrent use.
 // Changes during iteration may or may not be reflected.
 func (c *Cache[K, V]) Range() iter.Seq2[K, V] {
 	return func(yield func(K, V) bool) {
-		//nolint:gosec // G115: Unix seconds fit in uint32 until year 2106
-		now := uint32(time.Now().Unix())
-		c.memory.entries.Range(func(key K, e *entry[K, V]) bool {
+		now := c.memory.nowNano()
+		c.memory.rangeEntries(func(key K, e *entry[K, V]) bool {
 			// Skip expired entries.
-			expiry := e.expirySec.Load()
+			expiry := e.expiryNano.Load()
 			if expiry != 0 && expiry < now {
 				return true
 			}
@@ -166,8 +296,25 @@ func (c *Cache[K, V]) Range() iter.Seq2[K, V] {
 }
 
 type config struct {
-	size       int
-	defaultTTL time.Duration
+	onEvict           any // func(K, V, RemovalReason), checked against the cache types in newS3FIFO
+	weigher           any // func(K, V) uint64, checked against the cache types in newS3FIFO
+	hasher            any // func(K) uint64, checked against the cache key type in newS3FIFO
+	codec             Codec
+	size              int
+	shards            int
+	maxWeight         uint64
+	defaultTTL        time.Duration
+	refreshAfter      time.Duration
+	expireAfterAccess time.Duration
+	errorTTL          time.Duration
+	recordStats       bool
+	recoverPanics     bool
+	cancelAbandoned   bool
+	activeExpiration  bool
+	extendStoreExpiry bool
+	writeBack         bool // TieredCache only
+	clock             Clock
+	writeBehind       *WriteBehindConfig // TieredCache only
 }
 
 // Option configures a Cache.
@@ -178,7 +325,85 @@ func Size(n int) Option {
 	return func(c *config) { c.size = n }
 }
 
+// MaxWeight bounds the cache by total weight instead of entry count.
+// Each entry weighs what the Weigher returns, or 1 without one. Size then only
+// hints at the expected number of entries, used to size internal structures.
+// An entry heavier than MaxWeight is rejected and reported as evicted.
+func MaxWeight(w uint64) Option {
+	return func(c *config) { c.maxWeight = w }
+}
+
+// Weigher sets the function that computes an entry's weight for MaxWeight,
+// such as its size in bytes. It is called once per write, outside any lock.
+// A zero weight counts as 1. Ignored unless MaxWeight is set.
+// Its key and value types must match the cache's.
+func Weigher[K comparable, V any](fn func(key K, value V) uint64) Option {
+	return func(c *config) { c.weigher = fn }
+}
+
+// Hasher sets the function that hashes keys for the ghost queue. Built-in
+// hashers cover strings, numbers, fixed-size arrays and structs of those without
+// allocating; other keys fall back to fmt. Equal keys must hash equally.
+// Its key type must match the cache's.
+func Hasher[K comparable](fn func(key K) uint64) Option {
+	return func(c *config) { c.hasher = fn }
+}
+
 // TTL sets default expiration. Default 0 (none).
 func TTL(d time.Duration) Option {
 	return func(c *config) { c.defaultTTL = d }
 }
+
+// RefreshAfter makes Fetch refresh entries ahead of expiry. Once an entry is
+// older than d, Fetch still returns the cached value immediately but also starts
+// one background reload through the same loader. If the reload fails, the error
+// is logged and the old value is kept until it expires. Default 0 (off).
+func RefreshAfter(d time.Duration) Option {
+	return func(c *config) { c.refreshAfter = d }
+}
+
+// ErrorTTL makes Fetch and FetchMany cache loader errors, including ErrNotFound,
+// for d. Until the error expires, they return it without calling the loader
+// again, so a failing backend is not hammered by every caller. Writes, Delete
+// and Flush clear cached errors. TieredCache keeps them in memory only.
+// Default 0 (off).
+func ErrorTTL(d time.Duration) Option {
+	return func(c *config) { c.errorTTL = d }
+}
+
+// RecoverPanics makes Fetch return a loader panic as a *PanicError instead of
+// re-panicking. Either way, callers waiting on the same load receive the
+// *PanicError and the key can be fetched again.
+func RecoverPanics() Option {
+	return func(c *config) { c.recoverPanics = true }
+}
+
+// CancelAbandonedLoads detaches Fetch loaders from the context of the caller
+// that started them. The loader's context is cancelled only once every caller
+// waiting for that load has given up. Without it, the loader gets the first
+// caller's context, and a cancelled first caller fails the load for everyone.
+func CancelAbandonedLoads() Option {
+	return func(c *config) { c.cancelAbandoned = true }
+}
+
+// ActiveExpiration starts a goroutine that removes expired entries about a
+// second after they expire, reporting them to OnEvict with ReasonExpired.
+// Without it, expired entries are hidden from Get but hold their memory until
+// eviction reaches them. Call Close to stop the goroutine. Default off.
+func ActiveExpiration() Option {
+	return func(c *config) { c.activeExpiration = true }
+}
+
+// RecordStats enables hit, miss, eviction and loader counters, read via Stats.
+// Default off: counting adds a few nanoseconds to every lookup.
+func RecordStats() Option {
+	return func(c *config) { c.recordStats = true }
+}
+
+// OnEvict registers a listener called whenever an entry leaves the cache or its
+// value is replaced, with the reason. The listener runs on the goroutine that
+// caused the removal, after internal locks are released, so it may call back
+// into the cache. Its key and value types must match the cache's.
+func OnEvict[K comparable, V any](fn func(key K, value V, reason RemovalReason)) Option {
+	return func(c *config) { c.onEvict = fn }
+}
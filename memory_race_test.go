@@ -3,6 +3,7 @@
 package fido
 
 import (
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -57,3 +58,33 @@ func TestCache_Fetch_CacheHitDuringSingleflight(t *testing.T) {
 
 	t.Logf("loader calls: %d", loaderCalls.Load())
 }
+
+func TestCache_MaxWeight_Concurrent(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(5000), Size(200), Weigher(byteWeigher))
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 2000 {
+				k := strconv.Itoa((g*2000 + i) % 700)
+				cache.Set(k, make([]byte, 1+i%60))
+				cache.Get(k)
+				if i%17 == 0 {
+					cache.Delete(k)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	m := cache.memory
+	if m.totalWeight > 5000 {
+		t.Errorf("total weight = %d; want <= 5000", m.totalWeight)
+	}
+	if m.small.weight+m.main.weight != m.totalWeight {
+		t.Errorf("queue weights sum to %d; total weight is %d", m.small.weight+m.main.weight, m.totalWeight)
+	}
+	if m.small.len+m.main.len != cache.Len() {
+		t.Errorf("queue lengths sum to %d; Len() = %d", m.small.len+m.main.len, cache.Len())
+	}
+}
@@ -2,6 +2,7 @@ package fido
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -40,8 +41,8 @@ func TestCache_Basic(t *testing.T) {
 func TestCache_WithTTL(t *testing.T) {
 	cache := New[string, string]()
 
-	// Set with short TTL (minimum 1 second granularity)
-	cache.SetTTL("temp", "value", 1*time.Second)
+	// Expiry has nanosecond precision, so short TTLs are honored exactly.
+	cache.SetTTL("temp", "value", 200*time.Millisecond)
 
 	// Should be available immediately
 	val, found := cache.Get("temp")
@@ -49,8 +50,13 @@ func TestCache_WithTTL(t *testing.T) {
 		t.Error("temp should be found immediately")
 	}
 
+	time.Sleep(100 * time.Millisecond)
+	if _, found := cache.Get("temp"); !found {
+		t.Error("temp should be found halfway through its TTL")
+	}
+
 	// Wait for expiration
-	time.Sleep(2 * time.Second)
+	time.Sleep(150 * time.Millisecond)
 
 	// Should be expired
 	_, found = cache.Get("temp")
@@ -209,6 +215,30 @@ func TestCache_DeleteNonExistent(t *testing.T) {
 	}
 }
 
+func TestCache_DeleteFunc(t *testing.T) {
+	cache := New[string, int](Shards(2), Size(8192))
+	for i := range 10 {
+		cache.Set(fmt.Sprintf("user:1:%d", i), i)
+		cache.Set(fmt.Sprintf("user:2:%d", i), i)
+	}
+
+	n := cache.DeleteFunc(func(k string, v int) bool {
+		return strings.HasPrefix(k, "user:1:") && v%2 == 0
+	})
+	if n != 5 {
+		t.Errorf("DeleteFunc removed %d entries; want 5", n)
+	}
+	if cache.Len() != 15 {
+		t.Errorf("Len() = %d; want 15", cache.Len())
+	}
+	if _, ok := cache.Get("user:1:2"); ok {
+		t.Error("matching entry should be deleted")
+	}
+	if _, ok := cache.Get("user:1:3"); !ok {
+		t.Error("entry failing the predicate should remain")
+	}
+}
+
 func TestCache_EvictFromMain(t *testing.T) {
 	// Cache with 20000 capacity (approx 10 per shard with 2048 shards)
 	cache := New[int, int](Size(20000))
@@ -368,7 +398,7 @@ func TestCache_SetTTL(t *testing.T) {
 		t.Error("default-ttl should be found")
 	}
 
-	// SetTTL uses explicit short TTL (1 second granularity)
+	// SetTTL uses explicit short TTL
 	cache.SetTTL("short-ttl", 2, 1*time.Second)
 	if _, found := cache.Get("short-ttl"); !found {
 		t.Error("short-ttl should be found immediately")
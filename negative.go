@@ -0,0 +1,104 @@
+package fido
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound may be returned by a Fetch loader to report that the key does not
+// exist. It is returned to callers unchanged; with ErrorTTL it is cached like any
+// other loader error, so repeated lookups of a missing key skip the backend.
+var ErrNotFound = errors.New("not found")
+
+// negativeCache remembers loader errors for ErrorTTL. A nil *negativeCache is
+// valid and caches nothing.
+type negativeCache[K comparable] struct {
+	errs *s3fifo[K, error]
+	ttl  time.Duration
+}
+
+// newNegativeCache returns the error cache for cfg, or nil without ErrorTTL.
+// Failures are usually a small fraction of keys, so it holds an eighth as many entries.
+func newNegativeCache[K comparable](cfg *config) *negativeCache[K] {
+	if cfg.errorTTL <= 0 {
+		return nil
+	}
+	size := cfg.size
+	if size <= 0 {
+		size = 16384
+	}
+	return &negativeCache[K]{
+		errs: newS3FIFO[K, error](&config{size: max(size/8, 64), clock: cfg.clock, hasher: cfg.hasher}),
+		ttl:  cfg.errorTTL,
+	}
+}
+
+// get returns the cached loader error for key, or nil.
+func (n *negativeCache[K]) get(key K) error {
+	if n == nil {
+		return nil
+	}
+	err, _ := n.errs.get(key)
+	return err
+}
+
+// set caches a loader error for ErrorTTL. Panics are bugs rather than
+// backend state, so a *PanicError is not cached.
+func (n *negativeCache[K]) set(key K, err error) {
+	var pe *PanicError
+	if n == nil || errors.As(err, &pe) {
+		return
+	}
+	n.errs.set(key, err, n.errs.now().Add(n.ttl).UnixNano())
+}
+
+// del forgets any cached error for key, so the next Fetch calls the loader.
+// Every write calls it, so a key without an error is checked without the lock.
+func (n *negativeCache[K]) del(key K) {
+	if n != nil && n.errs.present(key) {
+		n.errs.del(key)
+	}
+}
+
+// skip returns misses without the keys that have a cached loader error, and
+// the first of those errors other than ErrNotFound. Keys cached as ErrNotFound
+// are left out quietly, as a bulk loader omitting them would leave them.
+func (n *negativeCache[K]) skip(misses []K) ([]K, error) {
+	if n == nil {
+		return misses, nil
+	}
+	var first error
+	out := misses[:0]
+	for _, k := range misses {
+		switch err := n.get(k); {
+		case err == nil:
+			out = append(out, k)
+		case errors.Is(err, ErrNotFound):
+		case first == nil:
+			first = err
+		}
+	}
+	return out, first
+}
+
+// cacheBulkErrors caches the outcome of a bulk load of keys, as set does for
+// one key: err for every key if the loader failed, or else ErrNotFound for
+// each key it left out of vals.
+func cacheBulkErrors[K comparable, V any](n *negativeCache[K], keys []K, vals map[K]V, err error) {
+	if n == nil {
+		return
+	}
+	for _, k := range keys {
+		if err != nil {
+			n.set(k, err)
+		} else if _, ok := vals[k]; !ok {
+			n.set(k, ErrNotFound)
+		}
+	}
+}
+
+func (n *negativeCache[K]) flush() {
+	if n != nil {
+		n.errs.flush()
+	}
+}
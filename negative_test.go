@@ -0,0 +1,224 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCache_ErrorTTL(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+	backendErr := errors.New("backend down")
+
+	calls := 0
+	loader := func() (int, error) {
+		calls++
+		return 0, backendErr
+	}
+
+	for range 3 {
+		if _, err := cache.Fetch("a", loader); !errors.Is(err, backendErr) {
+			t.Fatalf("Fetch error = %v; want %v", err, backendErr)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader calls = %d; want 1 (error should be cached)", calls)
+	}
+
+	// Cached errors never show up as values.
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get should not find a key whose load failed")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d; want 0", cache.Len())
+	}
+}
+
+func TestCache_ErrorTTL_NotFound(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+
+	calls := 0
+	loader := func() (int, error) {
+		calls++
+		return 0, fmt.Errorf("user 42: %w", ErrNotFound)
+	}
+
+	for range 2 {
+		if _, err := cache.Fetch("a", loader); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch error = %v; want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader calls = %d; want 1", calls)
+	}
+}
+
+func TestCache_ErrorTTL_Expires(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+
+	if _, err := cache.Fetch("a", func() (int, error) { return 0, ErrNotFound }); err == nil {
+		t.Fatal("Fetch should fail")
+	}
+	e, _ := cache.negative.errs.getEntry("a")
+	e.expiryNano.Store(1) // expired in 1970
+
+	v, err := cache.Fetch("a", func() (int, error) { return 7, nil })
+	if err != nil || v != 7 {
+		t.Errorf("Fetch after error expired = %d, %v; want 7, nil", v, err)
+	}
+}
+
+func TestCache_ErrorTTL_DeleteClears(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+
+	for _, clear := range []func(){
+		func() { cache.Delete("a") },
+		func() { cache.Flush() },
+	} {
+		if _, err := cache.Fetch("a", func() (int, error) { return 0, ErrNotFound }); err == nil {
+			t.Fatal("Fetch should fail")
+		}
+		clear()
+		v, err := cache.Fetch("a", func() (int, error) { return 1, nil })
+		if err != nil || v != 1 {
+			t.Errorf("Fetch after clearing = %d, %v; want 1, nil", v, err)
+		}
+		cache.Delete("a")
+	}
+}
+
+func TestCache_ErrorTTL_WriteClears(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+
+	for name, write := range map[string]func(){
+		"Set":     func() { cache.Set("a", 1) },
+		"SetMany": func() { cache.SetMany(map[string]int{"a": 1}) },
+		"Compute": func() {
+			cache.Compute("a", func(int, bool) (int, ComputeOp) { return 1, SetOp })
+		},
+		"SetExpireAfterAccess": func() { cache.SetExpireAfterAccess("a", 1, time.Minute) },
+	} {
+		cache.Delete("a")
+		if _, err := cache.Fetch("a", func() (int, error) { return 0, ErrNotFound }); err == nil {
+			t.Fatal("Fetch should fail")
+		}
+		write()
+		if err := cache.negative.get("a"); err != nil {
+			t.Errorf("%s left the cached loader error %v", name, err)
+		}
+	}
+}
+
+func TestCache_ErrorTTL_WriteSkipsLock(t *testing.T) {
+	cache := New[string, int](ErrorTTL(time.Minute))
+	cache.negative.set("b", errors.New("backend down"))
+
+	// Writes to keys without a cached error must not wait for the error cache's lock.
+	cache.negative.errs.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		cache.Set("a", 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Set of a key without a cached error took the error cache lock")
+	}
+	cache.negative.errs.mu.Unlock()
+	<-done
+
+	cache.Set("b", 2)
+	if err := cache.negative.get("b"); err != nil {
+		t.Errorf("cached error for b = %v after a write; want nil", err)
+	}
+}
+
+func TestCache_ErrorTTL_Disabled(t *testing.T) {
+	cache := New[string, int]()
+
+	calls := 0
+	for range 3 {
+		_, _ = cache.Fetch("a", func() (int, error) { //nolint:errcheck // only counting calls
+			calls++
+			return 0, ErrNotFound
+		})
+	}
+	if calls != 3 {
+		t.Errorf("loader calls = %d; want 3 without ErrorTTL", calls)
+	}
+}
+
+func TestTieredCache_ErrorTTL(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, ErrorTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	calls := 0
+	loader := func(context.Context) (int, error) {
+		calls++
+		return 0, ErrNotFound
+	}
+	for range 3 {
+		if _, err := cache.Fetch(ctx, "a", loader); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch error = %v; want ErrNotFound", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("loader calls = %d; want 1", calls)
+	}
+	if n, _ := store.Len(ctx); n != 0 {
+		t.Errorf("store has %d entries; negative results must not be persisted", n)
+	}
+	if _, _, found, _ := store.Get(ctx, "a"); found {
+		t.Error("negative result was persisted")
+	}
+}
+
+func TestTieredCache_ErrorTTL_WriteClears(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int](), ErrorTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if _, err := cache.Fetch(ctx, "a", func(context.Context) (int, error) { return 0, ErrNotFound }); err == nil {
+		t.Fatal("Fetch should fail")
+	}
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.negative.get("a"); err != nil {
+		t.Errorf("Set left the cached loader error %v", err)
+	}
+}
+
+func TestTieredCache_ErrorTTL_CanceledNotCached(t *testing.T) {
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, ErrorTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := cache.Fetch(ctx, "a", func(context.Context) (int, error) {
+		cancel()
+		return 0, context.Canceled
+	}); err == nil {
+		t.Fatal("Fetch should fail")
+	}
+
+	v, err := cache.Fetch(context.Background(), "a", func(context.Context) (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Errorf("Fetch after canceled load = %d, %v; want 1, nil", v, err)
+	}
+}
@@ -0,0 +1,161 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Fetch_LoaderPanic(t *testing.T) {
+	cache := New[string, int]()
+
+	func() {
+		defer func() {
+			r := recover()
+			pe, ok := r.(*PanicError)
+			if !ok {
+				t.Fatalf("recovered %T (%v); want *PanicError", r, r)
+			}
+			if pe.Value != "boom" {
+				t.Errorf("PanicError.Value = %v; want boom", pe.Value)
+			}
+			if !strings.Contains(string(pe.Stack), "panic_test.go") {
+				t.Error("PanicError.Stack should include the loader's frame")
+			}
+		}()
+		_, _ = cache.Fetch("a", func() (int, error) { panic("boom") }) //nolint:errcheck // panics
+	}()
+
+	// The flight was cleaned up, so the key is not stuck.
+	if _, ok := cache.flights.Load("a"); ok {
+		t.Fatal("flight entry should be removed after a panic")
+	}
+	v, err := cache.Fetch("a", func() (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Errorf("Fetch after panic = %d, %v; want 1, nil", v, err)
+	}
+}
+
+func TestCache_Fetch_LoaderPanic_Waiters(t *testing.T) {
+	cache := New[string, int](RecoverPanics())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, 4)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		_, err := cache.Fetch("a", func() (int, error) {
+			close(started)
+			<-release
+			panic(errors.New("boom"))
+		})
+		errs <- err
+	})
+	<-started
+	for range 3 {
+		wg.Go(func() {
+			_, err := cache.Fetch("a", func() (int, error) { return 0, errors.New("waiter should not load") })
+			errs <- err
+		})
+	}
+	// Give waiters time to join the flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("Fetch error = %v; want *PanicError", err)
+			continue
+		}
+		if err.Error() == "" || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Error() = %q; want it to mention the panic value", err.Error())
+		}
+	}
+}
+
+func TestCache_Fetch_LoaderPanic_Unwrap(t *testing.T) {
+	cache := New[string, int](RecoverPanics())
+	sentinel := errors.New("sentinel")
+
+	_, err := cache.Fetch("a", func() (int, error) { panic(sentinel) })
+	if !errors.Is(err, sentinel) {
+		t.Errorf("Fetch error = %v; want it to wrap the panic value", err)
+	}
+}
+
+func TestCache_Fetch_LoaderPanic_NotNegativeCached(t *testing.T) {
+	cache := New[string, int](RecoverPanics(), ErrorTTL(time.Minute))
+
+	if _, err := cache.Fetch("a", func() (int, error) { panic("boom") }); err == nil {
+		t.Fatal("Fetch should fail")
+	}
+	v, err := cache.Fetch("a", func() (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Errorf("Fetch after panic = %d, %v; want 1, nil", v, err)
+	}
+}
+
+func TestCache_RefreshAfter_LoaderPanic(t *testing.T) {
+	cache := New[string, int](RefreshAfter(time.Minute))
+	cache.Set("a", 1)
+	makeStale(t, cache.memory, "a")
+
+	if _, err := cache.Fetch("a", func() (int, error) { panic("boom") }); err != nil {
+		t.Fatalf("Fetch stale: %v", err)
+	}
+	if call, ok := cache.flights.Load("a"); ok {
+		<-call.done
+	}
+
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get after panicking refresh = %d, %v; want 1, true", v, ok)
+	}
+}
+
+func TestTieredCache_Fetch_LoaderPanic(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	func() {
+		defer func() {
+			if _, ok := recover().(*PanicError); !ok {
+				t.Fatal("leader should re-panic with *PanicError")
+			}
+		}()
+		_, _ = cache.Fetch(ctx, "a", func(context.Context) (int, error) { panic("boom") }) //nolint:errcheck // panics
+	}()
+
+	v, err := cache.Fetch(ctx, "a", func(context.Context) (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Errorf("Fetch after panic = %d, %v; want 1, nil", v, err)
+	}
+}
+
+func TestTieredCache_Fetch_LoaderPanic_Recovered(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int](), RecoverPanics(), RecordStats())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	_, err = cache.Fetch(ctx, "a", func(context.Context) (int, error) { panic("boom") })
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Fetch error = %v; want *PanicError", err)
+	}
+	if s := cache.Stats(); s.LoaderErrors != 1 {
+		t.Errorf("LoaderErrors = %d; want 1", s.LoaderErrors)
+	}
+}
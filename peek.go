@@ -0,0 +1,108 @@
+package fido
+
+import "time"
+
+// Queue identifies where an entry sits in the S3-FIFO eviction order.
+type Queue uint8
+
+const (
+	// QueueSmall holds new entries until they prove themselves.
+	QueueSmall Queue = iota
+	// QueueMain holds entries promoted from small or readmitted via the ghost queue.
+	QueueMain
+	// QueueDeathRow holds entries pending eviction. A Get resurrects them to main.
+	QueueDeathRow
+)
+
+func (q Queue) String() string {
+	switch q {
+	case QueueSmall:
+		return "small"
+	case QueueMain:
+		return "main"
+	case QueueDeathRow:
+		return "death row"
+	default:
+		return "unknown"
+	}
+}
+
+// EntryInfo is a snapshot of a cached entry and its eviction state.
+type EntryInfo[V any] struct {
+	Value    V
+	Expiry   time.Time // zero if the entry never expires
+	Queue    Queue
+	Freq     uint32 // recent accesses, capped at 5; decays as the entry ages in main
+	PeakFreq uint32 // accesses since insertion, capped at 21; drives death row admission
+}
+
+// Peek returns the value for key like Get, but without counting as an access:
+// frequency counters, death row and stats are left untouched.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	return c.memory.peek(key)
+}
+
+// GetEntry returns key's value with its expiry and eviction state, without
+// counting as an access.
+func (c *Cache[K, V]) GetEntry(key K) (EntryInfo[V], bool) {
+	return c.memory.info(key)
+}
+
+// Peek returns the value for key from memory like Get, but without counting as an
+// access: frequency counters, death row and stats are left untouched.
+// Does not consult the persistence layer.
+func (c *TieredCache[K, V]) Peek(key K) (V, bool) {
+	return c.memory.peek(key)
+}
+
+// GetEntry returns key's value in memory with its expiry and eviction state,
+// without counting as an access. Does not consult the persistence layer.
+func (c *TieredCache[K, V]) GetEntry(key K) (EntryInfo[V], bool) {
+	return c.memory.info(key)
+}
+
+// peek is get without the frequency bump or death row resurrection.
+func (c *s3fifo[K, V]) peek(key K) (V, bool) {
+	if c.shards != nil {
+		return c.shard(key).peek(key)
+	}
+	ent, ok := c.entries.Load(key)
+	if !ok || c.expired(ent) {
+		var zero V
+		return zero, false
+	}
+	return ent.loadValue()
+}
+
+// info is peek that also reports the entry's eviction state.
+func (c *s3fifo[K, V]) info(key K) (EntryInfo[V], bool) {
+	if c.shards != nil {
+		return c.shard(key).info(key)
+	}
+	ent, ok := c.entries.Load(key)
+	if !ok || c.expired(ent) {
+		return EntryInfo[V]{}, false
+	}
+	v, ok := ent.loadValue()
+	if !ok {
+		return EntryInfo[V]{}, false
+	}
+
+	flags := ent.freqFlags.Load()
+	info := EntryInfo[V]{
+		Value:    v,
+		Queue:    QueueMain,
+		Freq:     flags & freqMask,
+		PeakFreq: (flags >> peakFreqShift) & peakFreqMask,
+	}
+	switch {
+	case flags&onDeathRowBit != 0:
+		info.Queue = QueueDeathRow
+	case flags&inSmallBit != 0:
+		info.Queue = QueueSmall
+	}
+	if exp := ent.expiryNano.Load(); exp != 0 {
+		info.Expiry = time.Unix(0, exp)
+	}
+	return info, true
+}
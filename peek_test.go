@@ -0,0 +1,117 @@
+package fido
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCache_Peek(t *testing.T) {
+	cache := New[string, int](RecordStats())
+	cache.Set("a", 1)
+
+	for range 3 {
+		if v, ok := cache.Peek("a"); !ok || v != 1 {
+			t.Fatalf("Peek(a) = %d, %v; want 1, true", v, ok)
+		}
+	}
+	if _, ok := cache.Peek("missing"); ok {
+		t.Error("Peek should miss a missing key")
+	}
+
+	e, _ := cache.memory.getEntry("a")
+	if e.freq() != 0 || e.peakFreq() != 0 {
+		t.Errorf("freq, peakFreq = %d, %d; want 0, 0 after Peek", e.freq(), e.peakFreq())
+	}
+	if s := cache.Stats(); s.Hits != 0 || s.Misses != 0 {
+		t.Errorf("Hits, Misses = %d, %d; want 0, 0 after Peek", s.Hits, s.Misses)
+	}
+}
+
+func TestCache_Peek_Expired(t *testing.T) {
+	cache := New[string, int]()
+	cache.memory.set("a", 1, nowNano()-1)
+
+	if _, ok := cache.Peek("a"); ok {
+		t.Error("Peek should miss an expired entry")
+	}
+	if _, ok := cache.GetEntry("a"); ok {
+		t.Error("GetEntry should miss an expired entry")
+	}
+}
+
+func TestCache_GetEntry(t *testing.T) {
+	cache := New[string, int](TTL(time.Hour))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+
+	info, ok := cache.GetEntry("a")
+	if !ok {
+		t.Fatal("GetEntry(a) should find the entry")
+	}
+	if info.Value != 1 || info.Queue != QueueSmall || info.Freq != 2 || info.PeakFreq != 2 {
+		t.Errorf("GetEntry(a) = %+v; want value 1 in small with freq 2, peak 2", info)
+	}
+	if d := time.Until(info.Expiry); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiry in %v; want about an hour", d)
+	}
+
+	// GetEntry itself is not an access.
+	if info, _ := cache.GetEntry("a"); info.Freq != 2 {
+		t.Errorf("freq after GetEntry = %d; want 2", info.Freq)
+	}
+}
+
+func TestCache_GetEntry_DeathRow(t *testing.T) {
+	cache := New[int, int](Size(100))
+	for i := range 50 {
+		cache.Set(i, i)
+	}
+
+	e, _ := cache.memory.getEntry(7)
+	e.setFreqPeak(0, maxPeakFreq)
+	cache.memory.small.remove(e)
+	cache.memory.sendToDeathRow(e)
+
+	info, ok := cache.GetEntry(7)
+	if !ok || info.Queue != QueueDeathRow {
+		t.Fatalf("GetEntry(7) = %+v, %v; want an entry on death row", info, ok)
+	}
+	if v, ok := cache.Peek(7); !ok || v != 7 {
+		t.Errorf("Peek(7) = %d, %v; want 7, true", v, ok)
+	}
+	if !e.onDeathRow() {
+		t.Error("Peek should not resurrect a death row entry")
+	}
+	if info.Queue.String() != "death row" {
+		t.Errorf("Queue.String() = %q; want %q", info.Queue.String(), "death row")
+	}
+}
+
+func TestTieredCache_Peek(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set(ctx, "stored", 2, time.Time{}); err != nil {
+		t.Fatalf("store.Set: %v", err)
+	}
+
+	if v, ok := cache.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %d, %v; want 1, true", v, ok)
+	}
+	if _, ok := cache.Peek("stored"); ok {
+		t.Error("Peek should not consult persistence")
+	}
+	if info, ok := cache.GetEntry("a"); !ok || info.Value != 1 || !info.Expiry.IsZero() {
+		t.Errorf("GetEntry(a) = %+v, %v; want value 1 with no expiry", info, ok)
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"iter"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/puzpuzpuz/xsync/v4"
@@ -13,17 +17,45 @@ import (
 
 const asyncTimeout = 5 * time.Second
 
+// clockSetter is implemented by stores whose expiry checks can use a Clock.
+// The parameter is unnamed so that stores need not import fido.
+type clockSetter interface {
+	SetClock(clock interface{ Now() time.Time })
+}
+
 // TieredCache combines an in-memory cache with persistent storage.
 type TieredCache[K comparable, V any] struct {
-	Store      Store[K, V] // direct access to persistence layer
-	flights    *xsync.Map[K, *flightCall[V]]
-	memory     *s3fifo[K, V]
-	defaultTTL time.Duration
+	Store       Store[K, V] // direct access to persistence layer
+	flights     *xsync.Map[K, *flightCall[V]]
+	reads       *xsync.Map[K, *flightCall[storedValue[V]]] // store reads shared by concurrent misses
+	memory      *s3fifo[K, V]
+	negative    *negativeCache[K] // loader errors, never persisted
+	queue       *writeQueue[K, V] // SetAsync writes, nil without WriteBehind
+	codec       Codec
+	defaultTTL  time.Duration
+	idle        time.Duration // ExpireAfterAccess window, 0 if none
+	extendStore bool
+	writeBack   bool // WriteBack: writes mark memory dirty instead of reaching the store
+
+	recoverPanics   bool
+	cancelAbandoned bool
+
+	// Async writes without WriteBehind, for Shutdown.
+	asyncMu sync.Mutex      // orders async.Add after closing is set
+	async   sync.WaitGroup  // SetAsync, ExtendStoreExpiry and write-back writes in flight
+	closing atomic.Bool     // set when Shutdown begins
+	closed  bool            // set when Shutdown stops taking write-backs; guarded by asyncMu
+	failed  []error         // writes that failed during Shutdown; guarded by asyncMu
+	abort   context.Context //nolint:containedctx // parent of async writes, cancelled when Shutdown gives up on them
+	cancel  context.CancelFunc
+
+	shutdownOnce sync.Once
+	shutdownErr  error // the result of the first Shutdown
 }
 
 // NewTiered creates a cache backed by the given store.
 func NewTiered[K comparable, V any](store Store[K, V], opts ...Option) (*TieredCache[K, V], error) {
-	cfg := &config{size: 16384}
+	cfg := &config{size: 16384, codec: JSONCodec{}}
 	for _, opt := range opts {
 		opt(cfg)
 	}
@@ -31,22 +63,56 @@ func NewTiered[K comparable, V any](store Store[K, V], opts ...Option) (*TieredC
 	if store == nil {
 		return nil, errors.New("store cannot be nil")
 	}
+	if cfg.expireAfterAccess > 0 {
+		cfg.defaultTTL = 0
+	}
 
 	cache := &TieredCache[K, V]{
-		Store:      store,
-		flights:    xsync.NewMap[K, *flightCall[V]](),
-		memory:     newS3FIFO[K, V](cfg),
-		defaultTTL: cfg.defaultTTL,
+		Store:       store,
+		flights:     xsync.NewMap[K, *flightCall[V]](),
+		reads:       xsync.NewMap[K, *flightCall[storedValue[V]]](),
+		memory:      newS3FIFO[K, V](cfg),
+		negative:    newNegativeCache[K](cfg),
+		codec:       cfg.codec,
+		defaultTTL:  cfg.defaultTTL,
+		idle:        max(cfg.expireAfterAccess, 0),
+		extendStore: cfg.extendStoreExpiry,
+		writeBack:   cfg.writeBack,
+
+		recoverPanics:   cfg.recoverPanics,
+		cancelAbandoned: cfg.cancelAbandoned,
+	}
+	cache.abort, cache.cancel = context.WithCancel(context.Background())
+	if cfg.extendStoreExpiry {
+		for _, s := range cache.memory.parts() {
+			s.onSlide = cache.extendStored
+		}
+	}
+	if cfg.writeBack {
+		writing := xsync.NewMap[K, *dirtyValue[V]]()
+		cache.memory.writing = writing
+		for _, s := range cache.memory.parts() {
+			s.writing = writing
+			s.onWriteBack = cache.writeEvicted
+		}
+	} else if cfg.writeBehind != nil {
+		cache.queue = newWriteQueue(store, cache.memory.stats, *cfg.writeBehind)
+	}
+	if cs, ok := store.(clockSetter); ok && cfg.clock != nil {
+		cs.SetClock(cfg.clock)
 	}
 
 	return cache, nil
 }
 
 // Get checks memory, then persistence. Found values are cached in memory.
+// Concurrent misses for a key share one store read.
 //
 //nolint:gocritic // unnamedResult: public API signature is intentionally clear
 func (c *TieredCache[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
-	if val, ok := c.memory.get(key); ok {
+	val, ok := c.memory.get(key)
+	c.memory.stats.recordLookup(ok)
+	if ok {
 		return val, true, nil
 	}
 
@@ -55,16 +121,11 @@ func (c *TieredCache[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
 		return zero, false, fmt.Errorf("invalid key: %w", err)
 	}
 
-	val, expiry, found, err := c.Store.Get(ctx, key)
+	val, _, found, err := c.readStore(ctx, key)
 	if err != nil {
 		return zero, false, fmt.Errorf("persistence load: %w", err)
 	}
-	if !found {
-		return zero, false, nil
-	}
-
-	c.memory.set(key, val, timeToSec(expiry))
-	return val, true, nil
+	return val, found, nil
 }
 
 // Set stores to memory first (always), then persistence.
@@ -76,50 +137,96 @@ func (c *TieredCache[K, V]) Set(ctx context.Context, key K, value V) error {
 // SetTTL stores to memory first (always), then persistence with explicit TTL.
 // A zero or negative TTL means the entry never expires.
 func (c *TieredCache[K, V]) SetTTL(ctx context.Context, key K, value V, ttl time.Duration) error {
-	expiry := calculateExpiry(ttl, c.defaultTTL)
+	expiry := c.memory.calculateExpiry(ttl, c.defaultTTL)
 
 	if err := c.Store.ValidateKey(key); err != nil {
 		return err
 	}
 
-	c.memory.set(key, value, timeToSec(expiry))
+	c.memory.stats.recordSet()
+	if writeThrough, err := c.cacheWrite(key, value, timeToNano(expiry)); !writeThrough || err != nil {
+		return err
+	}
 
-	if err := c.Store.Set(ctx, key, value, expiry); err != nil {
+	if err := c.storeSet(ctx, key, value, c.storeExpiry(expiry)); err != nil {
+		c.memory.stats.recordStoreWrite(err)
 		return fmt.Errorf("persistence store failed: %w", err)
 	}
 	return nil
 }
 
+// storeSet calls Store.Set, ordered after the SetAsync writes to key that
+// WriteBehind has queued or in flight. Queued ones are dropped unwritten.
+func (c *TieredCache[K, V]) storeSet(ctx context.Context, key K, value V, expiry time.Time) error {
+	keys := []K{key}
+	if err := c.queue.hold(ctx, keys); err != nil {
+		return err
+	}
+	defer c.queue.finish(keys)
+	return c.Store.Set(ctx, key, value, expiry)
+}
+
+// storeDelete calls Store.Delete, dropping or waiting for key's SetAsync
+// writes first, so that none of them stores the key again.
+func (c *TieredCache[K, V]) storeDelete(ctx context.Context, key K) error {
+	keys := []K{key}
+	if err := c.queue.hold(ctx, keys); err != nil {
+		return err
+	}
+	defer c.queue.finish(keys)
+	return c.Store.Delete(ctx, key)
+}
+
 // SetAsync stores to memory synchronously, persistence asynchronously.
 // Uses the default TTL. Persistence errors are logged, not returned.
+// With WriteBehind, the write is queued; see SetAsyncTTL.
 func (c *TieredCache[K, V]) SetAsync(ctx context.Context, key K, value V) error {
 	return c.SetAsyncTTL(ctx, key, value, 0)
 }
 
 // SetAsyncTTL stores to memory synchronously, persistence asynchronously with explicit TTL.
-// Persistence errors are logged, not returned.
+// Persistence errors are logged, not returned. With WriteBehind, the write is
+// queued, and a full queue is handled by its OverflowPolicy: the error is
+// ctx.Err() for OverflowBlock, ErrQueueFull for OverflowDrop, and the store
+// error for OverflowSync. The value is cached in memory either way.
 func (c *TieredCache[K, V]) SetAsyncTTL(ctx context.Context, key K, value V, ttl time.Duration) error {
-	expiry := calculateExpiry(ttl, c.defaultTTL)
+	expiry := c.memory.calculateExpiry(ttl, c.defaultTTL)
 
 	if err := c.Store.ValidateKey(key); err != nil {
 		return err
 	}
+	if c.closing.Load() || c.queue == nil && !c.writeBack && !c.beginAsync() {
+		return ErrClosed
+	}
 
-	c.memory.set(key, value, timeToSec(expiry))
+	c.memory.stats.recordSet()
+	if writeThrough, err := c.cacheWrite(key, value, timeToNano(expiry)); !writeThrough || err != nil {
+		return err
+	}
 
+	if c.queue != nil {
+		return c.queue.add(ctx, key, queuedWrite[V]{ctx: ctx, value: value, expiry: c.storeExpiry(expiry)})
+	}
 	go func() {
-		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), asyncTimeout)
+		storeCtx, cancel := c.asyncContext(ctx)
 		defer cancel()
-		if err := c.Store.Set(storeCtx, key, value, expiry); err != nil {
+		err := c.Store.Set(storeCtx, key, value, c.storeExpiry(expiry))
+		if err != nil {
+			c.memory.stats.recordStoreWrite(err)
 			slog.Error("async persistence failed", "key", key, "error", err)
 		}
+		c.endAsync(key, err)
 	}()
 
 	return nil
 }
 
-// Fetch returns cached value or calls loader. Concurrent calls share one loader.
+// Fetch returns cached value or calls loader. Concurrent calls share one loader,
+// and calls made while FetchMany is loading the key wait for it; if its loader
+// leaves the key out of its result, Fetch returns ErrNotFound.
 // Computed values are stored with the default TTL.
+// With ErrorTTL, loader errors are cached in memory and returned until they expire.
+// A caller waiting on another caller's load returns ctx.Err() as soon as ctx is done.
 func (c *TieredCache[K, V]) Fetch(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
 	return c.getSet(ctx, key, loader, 0)
 }
@@ -132,94 +239,309 @@ func (c *TieredCache[K, V]) FetchTTL(ctx context.Context, key K, ttl time.Durati
 func (c *TieredCache[K, V]) getSet(ctx context.Context, key K, loader func(context.Context) (V, error), ttl time.Duration) (V, error) {
 	var zero V
 
-	if val, ok := c.memory.get(key); ok {
+	val, ok := c.memory.get(key)
+	c.memory.stats.recordLookup(ok)
+	if ok {
+		if c.memory.refreshAfter > 0 && c.memory.claimRefresh(key) {
+			c.refresh(ctx, key, loader, ttl)
+		}
 		return val, nil
 	}
 
+	if err := c.negative.get(key); err != nil {
+		return zero, err
+	}
+
 	if err := c.Store.ValidateKey(key); err != nil {
 		return zero, fmt.Errorf("invalid key: %w", err)
 	}
 
-	val, expiry, found, err := c.Store.Get(ctx, key)
+	val, _, found, err := c.readStore(ctx, key)
 	if err != nil {
 		return zero, fmt.Errorf("persistence load: %w", err)
 	}
 	if found {
-		c.memory.set(key, val, timeToSec(expiry))
 		return val, nil
 	}
 
-	call, loaded := c.flights.LoadOrCompute(key, func() (*flightCall[V], bool) {
-		fc := &flightCall[V]{}
-		fc.wg.Add(1)
-		return fc, false
-	})
+	call, leader := joinFlight(ctx, c.flights, key, c.cancelAbandoned)
+	if !leader {
+		return awaitFlight(ctx, c.flights, key, call)
+	}
 
-	if loaded {
-		call.wg.Wait()
-		return call.val, call.err
+	if call.cancel == nil {
+		c.lead(ctx, key, call, loader, ttl)
+		val, err := call.val, call.err
+		repanic(err, c.recoverPanics)
+		return val, err
 	}
 
+	// The load must outlive this caller if others are still waiting for it.
+	go c.lead(call.ctx, key, call, loader, ttl)
+	val, err = awaitFlight(ctx, c.flights, key, call)
+	repanic(err, c.recoverPanics)
+	return val, err
+}
+
+// lead runs a flight this caller created: it re-checks memory and persistence,
+// which may have been filled since the first check, then calls the loader.
+func (c *TieredCache[K, V]) lead(ctx context.Context, key K, call *flightCall[V], loader func(context.Context) (V, error), ttl time.Duration) {
+	var zero V
 	if v, ok := c.memory.get(key); ok {
-		call.val = v
-		c.flights.Delete(key)
-		call.wg.Done()
-		return v, nil
+		finishFlight(c.flights, key, call, v, nil)
+		return
+	}
+	if err := c.negative.get(key); err != nil {
+		finishFlight(c.flights, key, call, zero, err)
+		return
 	}
 
-	val, expiry, found, err = c.Store.Get(ctx, key)
+	val, _, found, err := c.readStore(ctx, key)
 	if err != nil {
-		call.err = fmt.Errorf("persistence load: %w", err)
-		c.flights.Delete(key)
-		call.wg.Done()
-		return zero, call.err
+		finishFlight(c.flights, key, call, zero, fmt.Errorf("persistence load: %w", err))
+		return
 	}
 	if found {
-		c.memory.set(key, val, timeToSec(expiry))
-		call.val = val
-		c.flights.Delete(key)
-		call.wg.Done()
-		return val, nil
+		finishFlight(c.flights, key, call, val, nil)
+		return
 	}
 
-	val, err = loader(ctx)
+	val, err = c.load(ctx, key, loader, ttl)
+	// A failure caused by the caller giving up says nothing about the key.
+	if err != nil && ctx.Err() == nil {
+		c.negative.set(key, err)
+	}
+	finishFlight(c.flights, key, call, val, err)
+}
+
+// load calls loader and writes a successful result to memory and persistence.
+// Persistence failures are logged, not returned.
+func (c *TieredCache[K, V]) load(ctx context.Context, key K, loader func(context.Context) (V, error), ttl time.Duration) (V, error) {
+	val, err := safeLoad(func() (V, error) { return loader(ctx) })
+	c.memory.stats.recordLoad(err)
 	if err != nil {
-		call.err = err
-		c.flights.Delete(key)
-		call.wg.Done()
+		var zero V
 		return zero, err
 	}
 
-	exp := calculateExpiry(ttl, c.defaultTTL)
-	c.memory.set(key, val, timeToSec(exp))
+	exp := c.memory.calculateExpiry(ttl, c.defaultTTL)
+	if writeThrough, err := c.cacheWrite(key, val, timeToNano(exp)); !writeThrough || err != nil {
+		return val, nil //nolint:nilerr // the value was loaded; caching it is best effort once closed
+	}
 
-	if err := c.Store.Set(ctx, key, val, exp); err != nil {
+	if err := c.Store.Set(ctx, key, val, c.storeExpiry(exp)); err != nil {
+		c.memory.stats.recordStoreWrite(err)
 		slog.Warn("Fetch persistence failed", "key", key, "error", err)
 	}
+	return val, nil
+}
+
+// refresh reloads key in the background, keeping the cached value if the loader fails.
+// Like Cache.refresh, the reload shares flights with foreground Fetch calls.
+// The reload outlives the caller, so it runs with ctx's values but not its cancellation.
+func (c *TieredCache[K, V]) refresh(ctx context.Context, key K, loader func(context.Context) (V, error), ttl time.Duration) {
+	call, loaded := c.flights.LoadOrCompute(key, newFlight[V])
+	if loaded {
+		return
+	}
 
-	call.val = val
-	c.flights.Delete(key)
-	call.wg.Done()
+	go func() {
+		val, err := c.load(context.WithoutCancel(ctx), key, loader, ttl)
+		if err != nil {
+			slog.Warn("background refresh failed", "key", key, "error", err)
+		}
 
-	return val, nil
+		finishFlight(c.flights, key, call, val, err)
+	}()
+}
+
+// storedValue is the result of a store read shared through TieredCache.reads.
+type storedValue[V any] struct {
+	value  V
+	expiry time.Time
+	found  bool
+	retry  bool // the leader gave up or panicked, so waiters must read for themselves
+}
+
+// readStore is storeGet shared by concurrent callers: the first caller for key
+// reads the store and caches a found value in memory, while the others wait for
+// its result. If the leader's ctx ends its read, or it panics, the waiters read
+// again rather than fail with it.
+//
+//nolint:gocritic // unnamedResult: mirrors the Store.Get signature
+func (c *TieredCache[K, V]) readStore(ctx context.Context, key K) (V, time.Time, bool, error) {
+	for {
+		call, leader := joinFlight(ctx, c.reads, key, false)
+		if leader {
+			return c.leadRead(ctx, key, call)
+		}
+		r, err := awaitFlight(ctx, c.reads, key, call)
+		if !r.retry || ctx.Err() != nil {
+			return r.value, r.expiry, r.found, err
+		}
+	}
+}
+
+// leadRead reads key for the read flight call, caching a found value in memory,
+// and publishes the result to its waiters even if the store panics.
+func (c *TieredCache[K, V]) leadRead(ctx context.Context, key K, call *flightCall[storedValue[V]]) (val V, expiry time.Time, found bool, err error) {
+	done := false
+	defer func() {
+		retry := !done || err != nil && ctx.Err() != nil
+		finishFlight(c.reads, key, call, storedValue[V]{value: val, expiry: expiry, found: found, retry: retry}, err)
+	}()
+	val, expiry, found, err = c.storeGet(ctx, key)
+	if err == nil && found {
+		c.memory.set(key, val, c.loadedExpiry(expiry))
+	}
+	done = true
+	return val, expiry, found, err
+}
+
+// storeGet calls Store.Get, recording hit, miss, error and latency stats.
+// A value still being written back with WriteBack is returned without a store call.
+//
+//nolint:gocritic // unnamedResult: mirrors the Store.Get signature
+func (c *TieredCache[K, V]) storeGet(ctx context.Context, key K) (V, time.Time, bool, error) {
+	if dv, ok := c.memory.staged(key); ok {
+		return dv.value, c.dirtyExpiry(dv), true, nil
+	}
+	if c.memory.stats == nil {
+		return c.Store.Get(ctx, key)
+	}
+	start := time.Now()
+	val, expiry, found, err := c.Store.Get(ctx, key)
+	c.memory.stats.recordStoreGet(start, found, err)
+	return val, expiry, found, err
 }
 
-// Delete removes from memory and persistence.
+// Delete removes from memory and persistence, including a value not yet
+// written back with WriteBack or still queued by WriteBehind.
 func (c *TieredCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.memory.stats.recordDelete()
 	c.memory.del(key)
+	c.negative.del(key)
 
 	if err := c.Store.ValidateKey(key); err != nil {
 		return fmt.Errorf("invalid key: %w", err)
 	}
-	if err := c.Store.Delete(ctx, key); err != nil {
+	if err := c.storeDelete(ctx, key); err != nil {
 		return fmt.Errorf("persistence delete: %w", err)
 	}
 	return nil
 }
 
-// Flush clears memory and persistence. Returns total entries removed.
+// DeletePrefix removes every entry whose key starts with prefix from memory and
+// persistence. Returns total entries removed, as Flush does.
+// Only usable when K is string. Uses the store's PrefixDeleter if it has one, and
+// otherwise deletes the keys listed by its PrefixScanner; with neither, returns
+// an error wrapping errors.ErrUnsupported and removes nothing.
+func (c *TieredCache[K, V]) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	if _, ok := any(prefix).(K); !ok {
+		return 0, fmt.Errorf("DeletePrefix needs string keys, not %T: %w", *new(K), errors.ErrUnsupported)
+	}
+	deleter, canDelete := c.Store.(PrefixDeleter)
+	scanner, canScan := c.Store.(PrefixScanner[V])
+	if !canDelete && !canScan {
+		return 0, fmt.Errorf("store %T implements neither PrefixDeleter nor PrefixScanner: %w", c.Store, errors.ErrUnsupported)
+	}
+
+	// Persistence first, so a concurrent Get cannot reload a deleted entry into memory.
+	var persistRemoved int
+	var err error
+	if canDelete {
+		persistRemoved, err = c.deletePrefix(ctx, deleter, prefix)
+	} else {
+		persistRemoved, err = c.deleteScanned(ctx, scanner, prefix)
+	}
+
+	var keys []K
+	match := hasPrefix[K](prefix)
+	for k := range c.Range() {
+		if match(k) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		c.memory.stats.recordDelete()
+		c.memory.del(k)
+		c.negative.del(k)
+	}
+	return len(keys) + persistRemoved, err
+}
+
+// deletePrefix calls the store's DeletePrefix, holding back the SetAsync writes
+// queued or in flight for keys with prefix as storeDelete does.
+func (c *TieredCache[K, V]) deletePrefix(ctx context.Context, deleter PrefixDeleter, prefix string) (int, error) {
+	held := c.queue.queued(hasPrefix[K](prefix))
+	if err := c.queue.hold(ctx, held); err != nil {
+		return 0, fmt.Errorf("persistence delete prefix: %w", err)
+	}
+	defer c.queue.finish(held)
+	n, err := deleter.DeletePrefix(ctx, prefix)
+	if err != nil {
+		return n, fmt.Errorf("persistence delete prefix: %w", err)
+	}
+	return n, nil
+}
+
+// deleteScanned deletes the keys with prefix listed by scanner from persistence
+// one at a time, along with those SetAsync has queued or in flight, which are
+// held back as deletePrefix holds them. K must be string. The keys are
+// collected first so that no store is modified while it is being scanned.
+// Returns the number of listed keys deleted.
+func (c *TieredCache[K, V]) deleteScanned(ctx context.Context, scanner PrefixScanner[V], prefix string) (int, error) {
+	queued := c.queue.queued(hasPrefix[K](prefix))
+	var keys []K
+	for name := range scanner.Keys(ctx, prefix) {
+		k, _ := any(name).(K)
+		keys = append(keys, k)
+	}
+	listed := len(keys)
+	for _, k := range queued {
+		if !slices.Contains(keys[:listed], k) {
+			keys = append(keys, k)
+		}
+	}
+	if err := c.queue.hold(ctx, keys); err != nil {
+		return 0, fmt.Errorf("persistence delete prefix: %w", err)
+	}
+	defer c.queue.finish(keys)
+
+	n := 0
+	var errs []error
+	for i, k := range keys {
+		if err := c.Store.Delete(ctx, k); err != nil {
+			errs = append(errs, fmt.Errorf("persistence delete %v: %w", k, err))
+			continue
+		}
+		if i < listed {
+			n++
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// hasPrefix returns a match for string keys that start with prefix.
+func hasPrefix[K comparable](prefix string) func(K) bool {
+	return func(k K) bool {
+		s, _ := any(k).(string)
+		return strings.HasPrefix(s, prefix)
+	}
+}
+
+// Flush clears memory and persistence, including values not yet written back
+// with WriteBack or still queued by WriteBehind. Returns total entries removed.
 func (c *TieredCache[K, V]) Flush(ctx context.Context) (int, error) {
+	c.negative.flush()
+	c.memory.tags.clear()
 	memoryRemoved := c.memory.flush()
+	if c.writeBack {
+		c.memory.writing.Clear()
+	}
+	if err := c.queue.drop(ctx); err != nil {
+		return memoryRemoved, fmt.Errorf("persistence flush: %w", err)
+	}
 	persistRemoved, err := c.Store.Flush(ctx)
 	if err != nil {
 		return memoryRemoved, fmt.Errorf("persistence flush: %w", err)
@@ -227,6 +549,12 @@ func (c *TieredCache[K, V]) Flush(ctx context.Context) (int, error) {
 	return memoryRemoved + persistRemoved, nil
 }
 
+// Stats returns a snapshot of the memory and persistence counters.
+// All counters are zero unless the cache was created with RecordStats.
+func (c *TieredCache[K, V]) Stats() Stats {
+	return c.memory.stats.snapshot()
+}
+
 // Len returns the memory cache size. Use Store.Len for persistence count.
 func (c *TieredCache[K, V]) Len() int {
 	return c.memory.len()
@@ -238,11 +566,10 @@ func (c *TieredCache[K, V]) Len() int {
 // Changes during iteration may or may not be reflected.
 func (c *TieredCache[K, V]) Range() iter.Seq2[K, V] {
 	return func(yield func(K, V) bool) {
-		//nolint:gosec // G115: Unix seconds fit in uint32 until year 2106
-		now := uint32(time.Now().Unix())
-		c.memory.entries.Range(func(key K, e *entry[K, V]) bool {
+		now := c.memory.nowNano()
+		c.memory.rangeEntries(func(key K, e *entry[K, V]) bool {
 			// Skip expired entries.
-			expiry := e.expirySec.Load()
+			expiry := e.expiryNano.Load()
 			if expiry != 0 && expiry < now {
 				return true
 			}
@@ -259,10 +586,8 @@ func (c *TieredCache[K, V]) Range() iter.Seq2[K, V] {
 	}
 }
 
-// Close releases store resources.
+// Close is Shutdown without a deadline: it waits for every pending async write,
+// stops the ActiveExpiration goroutine, if any, and releases store resources.
 func (c *TieredCache[K, V]) Close() error {
-	if err := c.Store.Close(); err != nil {
-		return fmt.Errorf("close persistence: %w", err)
-	}
-	return nil
+	return c.Shutdown(context.Background())
 }
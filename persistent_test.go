@@ -2,7 +2,11 @@ package fido
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"maps"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -1902,3 +1906,113 @@ func TestTieredCache_Fetch_SecondStoreGetFound(t *testing.T) {
 		t.Error("loader should not be called when second store.Get finds value")
 	}
 }
+
+// scanStore adds a PrefixScanner to mockStore.
+type scanStore[V any] struct {
+	*mockStore[string, V]
+}
+
+func (s *scanStore[V]) Keys(ctx context.Context, prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for k := range s.Range(ctx, prefix) {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func (s *scanStore[V]) Range(_ context.Context, prefix string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		s.mu.RLock()
+		data := maps.Clone(s.data)
+		s.mu.RUnlock()
+		for k, e := range data {
+			if strings.HasPrefix(k, prefix) && !yield(k, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// prefixStore adds a PrefixDeleter to scanStore, counting its calls.
+type prefixStore[V any] struct {
+	*scanStore[V]
+	calls int
+}
+
+func (s *prefixStore[V]) DeletePrefix(_ context.Context, prefix string) (int, error) {
+	s.calls++
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.data, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestTieredCache_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	scanner := &scanStore[int]{mockStore: newMockStore[string, int]()}
+	deleter := &prefixStore[int]{scanStore: &scanStore[int]{mockStore: newMockStore[string, int]()}}
+
+	for name, store := range map[string]Store[string, int]{"scanner": scanner, "deleter": deleter} {
+		t.Run(name, func(t *testing.T) {
+			cache, err := NewTiered[string, int](store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range []string{"user:1:a", "user:1:b", "user:12:a", "post:1"} {
+				if err := cache.Set(ctx, k, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cache.memory.del("user:1:b") // evicted from memory, still persisted
+
+			n, err := cache.DeletePrefix(ctx, "user:1:")
+			if err != nil {
+				t.Fatalf("DeletePrefix: %v", err)
+			}
+			if n != 3 {
+				t.Errorf("DeletePrefix removed %d entries; want 3 (1 memory + 2 persisted)", n)
+			}
+			for k, want := range map[string]bool{"user:1:a": false, "user:1:b": false, "user:12:a": true, "post:1": true} {
+				if _, ok, err := cache.Get(ctx, k); err != nil || ok != want {
+					t.Errorf("Get(%s) = %v, %v; want %v, nil", k, ok, err, want)
+				}
+			}
+		})
+	}
+	if deleter.calls != 1 {
+		t.Errorf("PrefixDeleter calls = %d; want 1", deleter.calls)
+	}
+}
+
+func TestTieredCache_DeletePrefix_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "user:1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.DeletePrefix(ctx, "user:"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("DeletePrefix without a scanner = %v; want errors.ErrUnsupported", err)
+	}
+	if _, ok := cache.memory.get("user:1"); !ok {
+		t.Error("unsupported DeletePrefix should remove nothing")
+	}
+
+	ints, err := NewTiered[int, int](newMockStore[int, int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ints.DeletePrefix(ctx, "1"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("DeletePrefix with int keys = %v; want errors.ErrUnsupported", err)
+	}
+}
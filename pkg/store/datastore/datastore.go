@@ -3,11 +3,14 @@ package datastore
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"iter"
+	"slices"
 	"strings"
 	"time"
 
@@ -26,6 +29,23 @@ type Store[K comparable, V any] struct {
 	kind       string
 	compressor compress.Compressor
 	ext        string
+	clock      interface{ Now() time.Time } // nil for the system clock
+}
+
+// SetClock sets the clock that expiry checks use in place of the system clock,
+// such as a fidotest.FakeClock. fido.NewTiered calls it for fido.WithClock.
+// Native Datastore TTL policies still delete entries by real time.
+// Call before the store is used.
+func (s *Store[K, V]) SetClock(clock interface{ Now() time.Time }) {
+	s.clock = clock
+}
+
+// now returns the current time by the store's clock.
+func (s *Store[K, V]) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
 }
 
 // ValidateKey checks if a key is valid for Datastore persistence.
@@ -102,7 +122,7 @@ func (s *Store[K, V]) Get(ctx context.Context, key K) (value V, expiry time.Time
 
 	// Check expiration - return miss but don't delete
 	// Cleanup is handled by native Datastore TTL or periodic Cleanup() calls
-	if !e.Expiry.IsZero() && time.Now().After(e.Expiry) {
+	if !e.Expiry.IsZero() && s.now().After(e.Expiry) {
 		return zero, time.Time{}, false, nil
 	}
 
@@ -138,7 +158,7 @@ func (s *Store[K, V]) Set(ctx context.Context, key K, value V, expiry time.Time)
 	e := entry{
 		Value:     base64.StdEncoding.EncodeToString(data),
 		Expiry:    expiry,
-		UpdatedAt: time.Now(),
+		UpdatedAt: s.now(),
 	}
 
 	if _, err := s.client.Put(ctx, s.makeKey(key), &e); err != nil {
@@ -160,7 +180,7 @@ func (s *Store[K, V]) Delete(ctx context.Context, key K) error {
 // maxAge specifies how old entries must be (based on expiry field) before deletion.
 // If native Datastore TTL is properly configured, this will find no entries.
 func (s *Store[K, V]) Cleanup(ctx context.Context, maxAge time.Duration) (int, error) {
-	cutoff := time.Now().Add(-maxAge)
+	cutoff := s.now().Add(-maxAge)
 
 	// Query for entries with expiry before cutoff
 	q := ds.NewQuery(s.kind).
@@ -195,16 +215,34 @@ func (s *Store[K, V]) Flush(ctx context.Context) (int, error) {
 	}
 
 	if len(keys) == 0 {
-		return 0, nil
+		return 0, s.flushTags(ctx)
 	}
 
 	if err := s.client.DeleteMulti(ctx, keys); err != nil {
 		return 0, fmt.Errorf("delete all entries: %w", err)
 	}
 
+	if err := s.flushTags(ctx); err != nil {
+		return len(keys), err
+	}
 	return len(keys), nil
 }
 
+// flushTags removes every tag record, which Flush leaves stale.
+func (s *Store[K, V]) flushTags(ctx context.Context) error {
+	keys, err := s.client.AllKeys(ctx, ds.NewQuery(s.tagKind()).KeysOnly())
+	if err != nil {
+		return fmt.Errorf("query tag keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := s.client.DeleteMulti(ctx, keys); err != nil {
+		return fmt.Errorf("delete tags: %w", err)
+	}
+	return nil
+}
+
 // Len returns the number of entries in Datastore.
 func (s *Store[K, V]) Len(ctx context.Context) (int, error) {
 	n, err := s.client.Count(ctx, ds.NewQuery(s.kind))
@@ -276,7 +314,7 @@ func (s *Store[K, V]) Range(ctx context.Context, prefix string) iter.Seq2[string
 			}
 
 			// Skip expired entries.
-			if !e.Expiry.IsZero() && time.Now().After(e.Expiry) {
+			if !e.Expiry.IsZero() && s.now().After(e.Expiry) {
 				continue
 			}
 
@@ -309,3 +347,110 @@ func (s *Store[K, V]) Range(ctx context.Context, prefix string) iter.Seq2[string
 		}
 	}
 }
+
+// DeletePrefix deletes every entry whose key starts with prefix and returns
+// the number deleted. Implements fido.PrefixDeleter (only usable when K is string).
+// Uses a keys-only query over the key range starting with prefix.
+func (s *Store[K, V]) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	start := ds.NameKey(s.kind, prefix, nil)
+	end := ds.NameKey(s.kind, prefix+"\xff", nil)
+
+	q := ds.NewQuery(s.kind).
+		Filter("__key__ >=", start).
+		Filter("__key__ <", end).
+		KeysOnly()
+
+	all, err := s.client.AllKeys(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("query keys: %w", err)
+	}
+	// Names carry the compression extension; skip entries written with another.
+	keys := slices.DeleteFunc(all, func(k *ds.Key) bool { return !strings.HasSuffix(k.Name, s.ext) })
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := s.client.DeleteMulti(ctx, keys); err != nil {
+		return 0, fmt.Errorf("delete keys: %w", err)
+	}
+	return len(keys), nil
+}
+
+// tagEntry records that a cache key carries a tag, in an entity of the tag kind.
+type tagEntry struct {
+	Tag string `datastore:"tag"`
+	Key string `datastore:"key,noindex"` // JSON-encoded cache key
+}
+
+// tagKind returns the kind of the tag entities, kept apart so that Len,
+// Flush and Keys over the entry kind never see them.
+func (s *Store[K, V]) tagKind() string {
+	return s.kind + "Tag"
+}
+
+// tagKey returns the entity key recording that the JSON-encoded key carries tag.
+// Hashed, as the two together may exceed Datastore's key name limit.
+func (s *Store[K, V]) tagKey(tag string, key []byte) *ds.Key {
+	sum := sha256.Sum256(append([]byte(tag+"\x00"), key...))
+	return ds.NameKey(s.tagKind(), hex.EncodeToString(sum[:]), nil)
+}
+
+// AddTags records that key carries tags. Implements fido.Tagger.
+func (s *Store[K, V]) AddTags(ctx context.Context, key K, tags []string) error {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := s.client.Put(ctx, s.tagKey(tag, k), &tagEntry{Tag: tag, Key: string(k)}); err != nil {
+			return fmt.Errorf("datastore put tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// TaggedKeys returns the keys recorded with tag. Implements fido.Tagger.
+func (s *Store[K, V]) TaggedKeys(ctx context.Context, tag string) ([]K, error) {
+	q := ds.NewQuery(s.tagKind()).Filter("tag =", tag).KeysOnly()
+	refs, err := s.client.AllKeys(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query tag: %w", err)
+	}
+
+	keys := make([]K, 0, len(refs))
+	var errs []error
+	for _, ref := range refs {
+		var e tagEntry
+		if err := s.client.Get(ctx, ref, &e); err != nil {
+			if !errors.Is(err, ds.ErrNoSuchEntity) {
+				errs = append(errs, fmt.Errorf("datastore get tag: %w", err))
+			}
+			continue
+		}
+		var k K
+		if err := json.Unmarshal([]byte(e.Key), &k); err != nil {
+			errs = append(errs, fmt.Errorf("unmarshal tagged key: %w", err))
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, errors.Join(errs...)
+}
+
+// RemoveTag forgets that keys carry tag. Implements fido.Tagger.
+func (s *Store[K, V]) RemoveTag(ctx context.Context, tag string, keys []K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	refs := make([]*ds.Key, len(keys))
+	for i, key := range keys {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("marshal key: %w", err)
+		}
+		refs[i] = s.tagKey(tag, k)
+	}
+	if err := s.client.DeleteMulti(ctx, refs); err != nil {
+		return fmt.Errorf("datastore delete tags: %w", err)
+	}
+	return nil
+}
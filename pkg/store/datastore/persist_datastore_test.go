@@ -397,6 +397,40 @@ func TestDatastorePersist_Keys(t *testing.T) {
 	}
 }
 
+func TestDatastorePersist_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	dp, cleanup := createTestStore[string, string](t, ctx)
+	defer cleanup()
+
+	entries := []string{"user:1:a", "user:1:b", "user:12:a", "post:1"}
+	for _, k := range entries {
+		if err := dp.Set(ctx, k, k, time.Time{}); err != nil {
+			t.Fatalf("Set %s: %v", k, err)
+		}
+	}
+
+	n, err := dp.DeletePrefix(ctx, "user:1:")
+	if err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeletePrefix deleted %d entries; want 2", n)
+	}
+	for k, want := range map[string]bool{"user:1:a": false, "user:1:b": false, "user:12:a": true, "post:1": true} {
+		if _, _, found, err := dp.Get(ctx, k); err != nil {
+			t.Fatalf("Get %s: %v", k, err)
+		} else if found != want {
+			t.Errorf("Get(%s) found = %v; want %v", k, found, want)
+		}
+	}
+
+	for _, k := range entries {
+		if err := dp.Delete(ctx, k); err != nil {
+			t.Logf("Delete error: %v", err)
+		}
+	}
+}
+
 func TestDatastorePersist_Range(t *testing.T) {
 	ctx := context.Background()
 	dp, cleanup := createTestStore[string, string](t, ctx)
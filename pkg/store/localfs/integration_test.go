@@ -7,6 +7,7 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -111,6 +112,83 @@ func TestFilePersist_TTL(t *testing.T) {
 	}
 }
 
+// fixedClock is a clock stopped at a given time.
+type fixedClock struct{ t time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.t }
+
+func TestFilePersist_SetClock(t *testing.T) {
+	dir := t.TempDir()
+	fp, err := New[string, string](filepath.Base(dir), filepath.Dir(dir))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	clock := &fixedClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fp.SetClock(clock)
+
+	ctx := context.Background()
+	// Long past by the system clock, but an hour ahead by the store's.
+	if err := fp.Set(ctx, "key", "value", clock.t.Add(time.Hour)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, _, found, err := fp.Get(ctx, "key"); err != nil || !found {
+		t.Fatalf("Get before expiry = %v, %v; want found", found, err)
+	}
+
+	clock.t = clock.t.Add(2 * time.Hour)
+	if _, _, found, err := fp.Get(ctx, "key"); err != nil || found {
+		t.Errorf("Get after expiry = %v, %v; want not found", found, err)
+	}
+}
+
+func TestFilePersist_Tags(t *testing.T) {
+	dir := t.TempDir()
+	fp, err := New[string, int](filepath.Base(dir), filepath.Dir(dir))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := fp.AddTags(ctx, "a", []string{"x", "y"}); err != nil {
+		t.Fatalf("AddTags: %v", err)
+	}
+	if err := fp.AddTags(ctx, "b", []string{"x"}); err != nil {
+		t.Fatalf("AddTags: %v", err)
+	}
+	if err := fp.Set(ctx, "a", 1, time.Time{}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if n, err := fp.Len(ctx); err != nil || n != 1 {
+		t.Errorf("Len() = %d, %v; want 1, tag records not counted", n, err)
+	}
+
+	keys, err := fp.TaggedKeys(ctx, "x")
+	if err != nil {
+		t.Fatalf("TaggedKeys: %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("TaggedKeys(x) = %v; want [a b]", keys)
+	}
+
+	if err := fp.RemoveTag(ctx, "x", []string{"a"}); err != nil {
+		t.Fatalf("RemoveTag: %v", err)
+	}
+	if keys, _ := fp.TaggedKeys(ctx, "x"); !slices.Equal(keys, []string{"b"}) { //nolint:errcheck // checked above
+		t.Errorf("TaggedKeys(x) after RemoveTag = %v; want [b]", keys)
+	}
+	if keys, _ := fp.TaggedKeys(ctx, "none"); len(keys) != 0 { //nolint:errcheck // checked above
+		t.Errorf("TaggedKeys(none) = %v; want empty", keys)
+	}
+
+	if _, err := fp.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if keys, _ := fp.TaggedKeys(ctx, "y"); len(keys) != 0 { //nolint:errcheck // checked above
+		t.Errorf("TaggedKeys(y) after Flush = %v; want empty", keys)
+	}
+}
+
 func TestFilePersist_Delete(t *testing.T) {
 	dir := t.TempDir()
 	fp, err := New[string, int](filepath.Base(dir), filepath.Dir(dir))
@@ -26,17 +26,21 @@ type Entry[K comparable, V any] struct {
 	UpdatedAt time.Time
 }
 
-const maxKeyLength = 127 // Maximum key length to avoid filesystem constraints
+const (
+	maxKeyLength = 127    // Maximum key length to avoid filesystem constraints
+	tagDir       = "tags" // Tag records: a directory per tag, a file per tagged key
+)
 
 // Store implements file-based persistence using local files with JSON encoding.
 //
 //nolint:govet // fieldalignment - current layout groups related fields logically (mutex with map it protects)
 type Store[K comparable, V any] struct {
 	subdirsMu   sync.RWMutex
-	Dir         string              // Exported for testing - directory path
-	subdirsMade map[string]bool     // Cache of created subdirectories
-	compressor  compress.Compressor // Compression algorithm
-	ext         string              // File extension based on compressor
+	Dir         string                       // Exported for testing - directory path
+	subdirsMade map[string]bool              // Cache of created subdirectories
+	compressor  compress.Compressor          // Compression algorithm
+	ext         string                       // File extension based on compressor
+	clock       interface{ Now() time.Time } // nil for the system clock
 }
 
 // New creates a new file-based persistence layer.
@@ -93,6 +97,21 @@ func New[K comparable, V any](cacheID, dir string, c ...compress.Compressor) (*S
 	}, nil
 }
 
+// SetClock sets the clock that expiry checks use in place of the system clock,
+// such as a fidotest.FakeClock. fido.NewTiered calls it for fido.WithClock.
+// Call before the store is used.
+func (s *Store[K, V]) SetClock(clock interface{ Now() time.Time }) {
+	s.clock = clock
+}
+
+// now returns the current time by the store's clock.
+func (s *Store[K, V]) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
 // ValidateKey checks if a key is valid for file persistence.
 // Since keys are hashed to SHA256, any characters are allowed.
 // Only length is validated to prevent memory issues.
@@ -111,11 +130,16 @@ func (*Store[K, V]) ValidateKey(key K) error {
 // Hashes the key and uses first 2 characters of hex hash as subdirectory for even distribution
 // (e.g., key "mykey" -> "a3/a3f2....j" or "a3/a3f2....s" with S2 compression).
 func (s *Store[K, V]) keyToFilename(key K) string {
-	sum := sha256.Sum256(fmt.Appendf(nil, "%v", key))
-	h := hex.EncodeToString(sum[:])
+	h := keyHash(key)
 	return filepath.Join(h[:2], h+s.ext)
 }
 
+// keyHash returns the hex SHA256 of key's string form.
+func keyHash[K comparable](key K) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%v", key))
+	return hex.EncodeToString(sum[:])
+}
+
 // Location returns the full file path where a key is stored.
 func (s *Store[K, V]) Location(key K) string {
 	return filepath.Join(s.Dir, s.keyToFilename(key))
@@ -151,7 +175,7 @@ func (s *Store[K, V]) Get(ctx context.Context, key K) (value V, expiry time.Time
 		)
 	}
 
-	if !e.Expiry.IsZero() && time.Now().After(e.Expiry) {
+	if !e.Expiry.IsZero() && s.now().After(e.Expiry) {
 		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
 			return zero, time.Time{}, false, fmt.Errorf("remove expired file: %w", err)
 		}
@@ -191,7 +215,7 @@ func (s *Store[K, V]) Set(ctx context.Context, key K, value V, expiry time.Time)
 		Key:       key,
 		Value:     value,
 		Expiry:    expiry,
-		UpdatedAt: time.Now(),
+		UpdatedAt: s.now(),
 	}
 
 	jsonData, err := json.Marshal(e)
@@ -237,7 +261,7 @@ func (s *Store[K, V]) isCacheFile(name string) bool {
 // Walks through all cache files and deletes those with expired timestamps.
 // Returns the count of deleted entries and any errors encountered.
 func (s *Store[K, V]) Cleanup(ctx context.Context, maxAge time.Duration) (int, error) {
-	cutoff := time.Now().Add(-maxAge)
+	cutoff := s.now().Add(-maxAge)
 	n := 0
 	var errs []error
 
@@ -328,6 +352,10 @@ func (s *Store[K, V]) Flush(ctx context.Context) (int, error) {
 		errs = append(errs, fmt.Errorf("walk directory: %w", walkErr))
 	}
 
+	if err := os.RemoveAll(filepath.Join(s.Dir, tagDir)); err != nil {
+		errs = append(errs, fmt.Errorf("remove tags: %w", err))
+	}
+
 	s.subdirsMu.Lock()
 	s.subdirsMade = make(map[string]bool)
 	s.subdirsMu.Unlock()
@@ -421,7 +449,7 @@ func (s *Store[K, V]) Range(ctx context.Context, prefix string) iter.Seq2[string
 			}
 
 			// Skip expired entries.
-			if !e.Expiry.IsZero() && time.Now().After(e.Expiry) {
+			if !e.Expiry.IsZero() && s.now().After(e.Expiry) {
 				return nil
 			}
 
@@ -441,3 +469,75 @@ func (s *Store[K, V]) Range(ctx context.Context, prefix string) iter.Seq2[string
 		})
 	}
 }
+
+// tagPath returns the directory holding tag's records.
+func (s *Store[K, V]) tagPath(tag string) string {
+	sum := sha256.Sum256([]byte(tag))
+	return filepath.Join(s.Dir, tagDir, hex.EncodeToString(sum[:]))
+}
+
+// AddTags records that key carries tags, as a file holding the key under each
+// tag's directory. Implements fido.Tagger.
+func (s *Store[K, V]) AddTags(ctx context.Context, key K, tags []string) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("encode key: %w", err)
+	}
+	name := keyHash(key)
+	for _, tag := range tags {
+		dir := s.tagPath(tag)
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("create tag directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			return fmt.Errorf("write tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// TaggedKeys returns the keys recorded with tag. Implements fido.Tagger.
+func (s *Store[K, V]) TaggedKeys(ctx context.Context, tag string) ([]K, error) {
+	files, err := os.ReadDir(s.tagPath(tag))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read tag directory: %w", err)
+	}
+
+	keys := make([]K, 0, len(files))
+	var errs []error
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return keys, err
+		}
+		data, err := os.ReadFile(filepath.Join(s.tagPath(tag), f.Name()))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("read tag %s: %w", f.Name(), err))
+			}
+			continue
+		}
+		var k K
+		if err := json.Unmarshal(data, &k); err != nil {
+			errs = append(errs, fmt.Errorf("decode tag %s: %w", f.Name(), err))
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, errors.Join(errs...)
+}
+
+// RemoveTag forgets that keys carry tag. Implements fido.Tagger.
+func (s *Store[K, V]) RemoveTag(ctx context.Context, tag string, keys []K) error {
+	dir := s.tagPath(tag)
+	var errs []error
+	for _, k := range keys {
+		if err := os.Remove(filepath.Join(dir, keyHash(k))); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("remove tag: %w", err))
+		}
+	}
+	_ = os.Remove(dir) //nolint:errcheck // only succeeds once no keys carry the tag
+	return errors.Join(errs...)
+}
@@ -67,3 +67,18 @@ func (*Store[K, V]) Len(_ context.Context) (int, error) {
 func (*Store[K, V]) Close() error {
 	return nil
 }
+
+// AddTags is a no-op and returns nil.
+func (*Store[K, V]) AddTags(_ context.Context, _ K, _ []string) error {
+	return nil
+}
+
+// TaggedKeys always returns no keys.
+func (*Store[K, V]) TaggedKeys(_ context.Context, _ string) ([]K, error) {
+	return nil, nil
+}
+
+// RemoveTag is a no-op and returns nil.
+func (*Store[K, V]) RemoveTag(_ context.Context, _ string, _ []K) error {
+	return nil
+}
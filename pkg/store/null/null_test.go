@@ -121,3 +121,22 @@ func TestClose(t *testing.T) {
 		t.Errorf("Close() error = %v; want nil", err)
 	}
 }
+
+func TestTags(t *testing.T) {
+	store := New[string, int]()
+	ctx := context.Background()
+
+	if err := store.AddTags(ctx, "key", []string{"tag"}); err != nil {
+		t.Errorf("AddTags() error = %v; want nil", err)
+	}
+	keys, err := store.TaggedKeys(ctx, "tag")
+	if err != nil {
+		t.Errorf("TaggedKeys() error = %v; want nil", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("TaggedKeys() = %v; want empty", keys)
+	}
+	if err := store.RemoveTag(ctx, "tag", []string{"key"}); err != nil {
+		t.Errorf("RemoveTag() error = %v; want nil", err)
+	}
+}
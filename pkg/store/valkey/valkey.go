@@ -20,8 +20,10 @@ const maxKeyLength = 512 // Maximum key length for Valkey
 type Store[K comparable, V any] struct {
 	client     valkey.Client
 	prefix     string // Key prefix to namespace cache entries
+	tagPrefix  string // Key prefix of tag sets, outside prefix so scans skip them
 	compressor compress.Compressor
 	ext        string
+	clock      interface{ Now() time.Time } // nil for the system clock
 }
 
 // New creates a new Valkey-based persistence layer.
@@ -54,11 +56,28 @@ func New[K comparable, V any](ctx context.Context, cacheID, addr string, c ...co
 	return &Store[K, V]{
 		client:     client,
 		prefix:     cacheID + ":",
+		tagPrefix:  cacheID + "#tag:",
 		compressor: comp,
 		ext:        comp.Extension(),
 	}, nil
 }
 
+// SetClock sets the clock that expiry checks use in place of the system clock,
+// such as a fidotest.FakeClock. fido.NewTiered calls it for fido.WithClock.
+// Valkey still expires keys by its own clock, after the TTL the store's clock
+// gives them. Call before the store is used.
+func (s *Store[K, V]) SetClock(clock interface{ Now() time.Time }) {
+	s.clock = clock
+}
+
+// now returns the current time by the store's clock.
+func (s *Store[K, V]) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
 // ValidateKey checks if a key is valid for Valkey persistence.
 func (*Store[K, V]) ValidateKey(key K) error {
 	k := fmt.Sprintf("%v", key)
@@ -118,12 +137,58 @@ func (s *Store[K, V]) Get(ctx context.Context, key K) (V, time.Time, bool, error
 	var exp time.Time
 	ms, err := resps[1].AsInt64()
 	if err == nil && ms > 0 {
-		exp = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		exp = s.now().Add(time.Duration(ms) * time.Millisecond)
 	}
 
 	return v, exp, true, nil
 }
 
+// GetMany retrieves several values from Valkey in one pipelined round trip.
+//
+//nolint:gocritic // unnamedResult - matches the fido.BatchGetter interface
+func (s *Store[K, V]) GetMany(ctx context.Context, keys []K) (map[K]V, map[K]time.Time, error) {
+	if len(keys) == 0 {
+		return map[K]V{}, map[K]time.Time{}, nil
+	}
+
+	cmds := make([]valkey.Completed, 0, 2*len(keys))
+	for _, key := range keys {
+		k := s.makeKey(key)
+		cmds = append(cmds, s.client.B().Get().Key(k).Build(), s.client.B().Pttl().Key(k).Build())
+	}
+
+	resps := s.client.DoMulti(ctx, cmds...)
+
+	vals := make(map[K]V, len(keys))
+	exps := make(map[K]time.Time, len(keys))
+	for i, key := range keys {
+		data, err := resps[2*i].AsBytes()
+		if err != nil {
+			if valkey.IsValkeyNil(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("valkey get: %w", err)
+		}
+
+		jsonData, err := s.compressor.Decode(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decompress: %w", err)
+		}
+
+		var v V
+		if err := json.Unmarshal(jsonData, &v); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal value: %w", err)
+		}
+		vals[key] = v
+
+		if ms, err := resps[2*i+1].AsInt64(); err == nil && ms > 0 {
+			exps[key] = s.now().Add(time.Duration(ms) * time.Millisecond)
+		}
+	}
+
+	return vals, exps, nil
+}
+
 // Set saves a value to Valkey with optional expiry.
 func (s *Store[K, V]) Set(ctx context.Context, key K, value V, expiry time.Time) error {
 	jsonData, err := json.Marshal(value)
@@ -140,7 +205,7 @@ func (s *Store[K, V]) Set(ctx context.Context, key K, value V, expiry time.Time)
 	var cmd valkey.Completed
 
 	if !expiry.IsZero() {
-		ttl := time.Until(expiry)
+		ttl := expiry.Sub(s.now())
 		if ttl <= 0 {
 			return nil // Already expired
 		}
@@ -155,6 +220,46 @@ func (s *Store[K, V]) Set(ctx context.Context, key K, value V, expiry time.Time)
 	return nil
 }
 
+// SetMany saves several values in one pipeline. A key missing from expiries has no expiry.
+// Implements fido.BatchSetter.
+func (s *Store[K, V]) SetMany(ctx context.Context, vals map[K]V, expiries map[K]time.Time) error {
+	cmds := make([]valkey.Completed, 0, len(vals))
+	for key, value := range vals {
+		jsonData, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("marshal value: %w", err)
+		}
+
+		data, err := s.compressor.Encode(jsonData)
+		if err != nil {
+			return fmt.Errorf("compress: %w", err)
+		}
+
+		k := s.makeKey(key)
+		expiry := expiries[key]
+		if expiry.IsZero() {
+			cmds = append(cmds, s.client.B().Set().Key(k).Value(string(data)).Build())
+			continue
+		}
+		ttl := expiry.Sub(s.now())
+		if ttl <= 0 {
+			continue // Already expired
+		}
+		cmds = append(cmds, s.client.B().Set().Key(k).Value(string(data)).Px(ttl).Build())
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, resp := range s.client.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			errs = append(errs, fmt.Errorf("valkey set: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Delete removes a value from Valkey.
 func (s *Store[K, V]) Delete(ctx context.Context, key K) error {
 	k := s.makeKey(key)
@@ -204,9 +309,32 @@ func (s *Store[K, V]) Flush(ctx context.Context) (int, error) {
 		}
 	}
 
+	if err := s.flushTags(ctx); err != nil {
+		return n, err
+	}
 	return n, nil
 }
 
+// flushTags removes every tag set, which Flush leaves stale.
+func (s *Store[K, V]) flushTags(ctx context.Context) error {
+	var cur uint64
+	for {
+		scan, err := s.client.Do(ctx, s.client.B().Scan().Cursor(cur).Match(s.tagPrefix+"*").Count(100).Build()).AsScanEntry()
+		if err != nil {
+			return fmt.Errorf("scan tags: %w", err)
+		}
+		if len(scan.Elements) > 0 {
+			if err := s.client.Do(ctx, s.client.B().Del().Key(scan.Elements...).Build()).Error(); err != nil {
+				return fmt.Errorf("delete tags: %w", err)
+			}
+		}
+		cur = scan.Cursor
+		if cur == 0 {
+			return nil
+		}
+	}
+}
+
 // Len returns the number of entries with this cache's prefix in Valkey.
 func (s *Store[K, V]) Len(ctx context.Context) (int, error) {
 	n := 0
@@ -343,3 +471,112 @@ func (s *Store[K, V]) Range(ctx context.Context, prefix string) iter.Seq2[string
 		}
 	}
 }
+
+// DeletePrefix deletes every entry whose key starts with prefix and returns
+// the number deleted. Implements fido.PrefixDeleter (only usable when K is string).
+// Uses SCAN with pattern matching and DEL per batch.
+func (s *Store[K, V]) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	n := 0
+	pat := s.prefix + globEscape(prefix) + "*" + s.ext
+	var cur uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return n, ctx.Err()
+		default:
+		}
+
+		scan, err := s.client.Do(ctx, s.client.B().Scan().Cursor(cur).Match(pat).Count(100).Build()).AsScanEntry()
+		if err != nil {
+			return n, fmt.Errorf("scan keys: %w", err)
+		}
+
+		if len(scan.Elements) > 0 {
+			c, err := s.client.Do(ctx, s.client.B().Del().Key(scan.Elements...).Build()).AsInt64()
+			if err != nil {
+				return n, fmt.Errorf("delete keys: %w", err)
+			}
+			n += int(c)
+		}
+
+		cur = scan.Cursor
+		if cur == 0 {
+			return n, nil
+		}
+	}
+}
+
+// globEscape quotes the characters special to SCAN MATCH patterns, so that a
+// prefix such as "user[1]" never deletes more than it names.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// AddTags records that key carries tags, as a member of a set per tag.
+// Implements fido.Tagger.
+func (s *Store[K, V]) AddTags(ctx context.Context, key K, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	member, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	cmds := make([]valkey.Completed, len(tags))
+	for i, tag := range tags {
+		cmds[i] = s.client.B().Sadd().Key(s.tagPrefix + tag).Member(string(member)).Build()
+	}
+	for _, resp := range s.client.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return fmt.Errorf("valkey sadd: %w", err)
+		}
+	}
+	return nil
+}
+
+// TaggedKeys returns the keys recorded with tag. Implements fido.Tagger.
+func (s *Store[K, V]) TaggedKeys(ctx context.Context, tag string) ([]K, error) {
+	members, err := s.client.Do(ctx, s.client.B().Smembers().Key(s.tagPrefix+tag).Build()).AsStrSlice()
+	if err != nil {
+		return nil, fmt.Errorf("valkey smembers: %w", err)
+	}
+	keys := make([]K, 0, len(members))
+	var errs []error
+	for _, m := range members {
+		var k K
+		if err := json.Unmarshal([]byte(m), &k); err != nil {
+			errs = append(errs, fmt.Errorf("unmarshal tagged key: %w", err))
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, errors.Join(errs...)
+}
+
+// RemoveTag forgets that keys carry tag. Implements fido.Tagger.
+func (s *Store[K, V]) RemoveTag(ctx context.Context, tag string, keys []K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	members := make([]string, len(keys))
+	for i, k := range keys {
+		m, err := json.Marshal(k)
+		if err != nil {
+			return fmt.Errorf("marshal key: %w", err)
+		}
+		members[i] = string(m)
+	}
+	if err := s.client.Do(ctx, s.client.B().Srem().Key(s.tagPrefix+tag).Member(members...).Build()).Error(); err != nil {
+		return fmt.Errorf("valkey srem: %w", err)
+	}
+	return nil
+}
@@ -1071,3 +1071,100 @@ func TestValkeyPersist_Keys_ContextCancellation(t *testing.T) {
 		_ = p.Delete(ctx, fmt.Sprintf("key-%d", i)) //nolint:errcheck // test cleanup
 	}
 }
+
+func TestValkeyPersist_GetMany(t *testing.T) {
+	skipIfNoValkey(t)
+
+	ctx := context.Background()
+	addr := os.Getenv("VALKEY_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	p, err := New[string, int](ctx, "test-cache-getmany", addr)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("Close error: %v", err)
+		}
+	}()
+
+	if err := p.Set(ctx, "a", 1, time.Time{}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := p.Set(ctx, "b", 2, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	vals, exps, err := p.GetMany(ctx, []string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if len(vals) != 2 || vals["a"] != 1 || vals["b"] != 2 {
+		t.Errorf("GetMany values = %v; want a=1 b=2", vals)
+	}
+	if !exps["a"].IsZero() {
+		t.Error("a should have no expiry")
+	}
+	if exps["b"].IsZero() {
+		t.Error("b should have an expiry")
+	}
+
+	for _, k := range []string{"a", "b"} {
+		if err := p.Delete(ctx, k); err != nil {
+			t.Logf("Delete error: %v", err)
+		}
+	}
+}
+
+func TestValkeyPersist_DeletePrefix(t *testing.T) {
+	skipIfNoValkey(t)
+
+	ctx := context.Background()
+	addr := os.Getenv("VALKEY_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	p, err := New[string, int](ctx, "test-cache-deleteprefix", addr)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() {
+		if _, err := p.Flush(ctx); err != nil {
+			t.Logf("Flush error: %v", err)
+		}
+		if err := p.Close(); err != nil {
+			t.Logf("Close error: %v", err)
+		}
+	}()
+
+	for _, k := range []string{"user:1:a", "user:1:b", "user:12:a", "user:[1]:a"} {
+		if err := p.Set(ctx, k, 1, time.Time{}); err != nil {
+			t.Fatalf("Set %s: %v", k, err)
+		}
+	}
+
+	n, err := p.DeletePrefix(ctx, "user:1:")
+	if err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeletePrefix deleted %d entries; want 2", n)
+	}
+	for k, want := range map[string]bool{"user:1:a": false, "user:1:b": false, "user:12:a": true, "user:[1]:a": true} {
+		if _, _, found, err := p.Get(ctx, k); err != nil {
+			t.Fatalf("Get %s: %v", k, err)
+		} else if found != want {
+			t.Errorf("Get(%s) found = %v; want %v", k, found, want)
+		}
+	}
+}
+
+func TestGlobEscape(t *testing.T) {
+	if got, want := globEscape(`a*b?[c]\d`), `a\*b\?\[c\]\\d`; got != want {
+		t.Errorf("globEscape = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// makeStale moves key's refresh point into the past.
+func makeStale[K comparable, V any](t *testing.T, c *s3fifo[K, V], key K) {
+	t.Helper()
+	e, ok := c.getEntry(key)
+	if !ok {
+		t.Fatalf("key %v not cached", key)
+	}
+	// refreshSec counts seconds from the cache's creation, so move that back.
+	c.epoch = min(c.epoch, nowNano()-2*int64(time.Second))
+	e.refreshSec.Store(1)
+}
+
+func TestCache_RefreshAfter(t *testing.T) {
+	cache := New[string, int](RefreshAfter(time.Minute), RecordStats())
+	cache.Set("a", 1)
+
+	// Fresh entries are served without calling the loader.
+	v, err := cache.Fetch("a", func() (int, error) { return 0, errors.New("unexpected load") })
+	if err != nil || v != 1 {
+		t.Fatalf("Fetch fresh = %d, %v; want 1, nil", v, err)
+	}
+
+	makeStale(t, cache.memory, "a")
+	release := make(chan struct{})
+	var calls atomic.Int32
+	loader := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 2, nil
+	}
+
+	// Stale entries are served immediately while one reload runs.
+	for range 3 {
+		v, err := cache.Fetch("a", loader)
+		if err != nil || v != 1 {
+			t.Fatalf("Fetch stale = %d, %v; want 1, nil", v, err)
+		}
+	}
+	call, ok := cache.flights.Load("a")
+	if !ok {
+		t.Fatal("background reload should be registered in flights")
+	}
+	close(release)
+	<-call.done
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader calls = %d; want 1", got)
+	}
+	if v, _ := cache.Get("a"); v != 2 {
+		t.Errorf("Get after refresh = %d; want 2", v)
+	}
+	if got := cache.Stats().LoaderCalls; got != 1 {
+		t.Errorf("LoaderCalls = %d; want 1", got)
+	}
+}
+
+func TestCache_RefreshAfter_Error(t *testing.T) {
+	cache := New[string, int](RefreshAfter(time.Minute), RecordStats())
+	cache.Set("a", 1)
+	makeStale(t, cache.memory, "a")
+
+	v, err := cache.Fetch("a", func() (int, error) { return 0, errors.New("backend down") })
+	if err != nil || v != 1 {
+		t.Fatalf("Fetch stale = %d, %v; want 1, nil", v, err)
+	}
+	if call, ok := cache.flights.Load("a"); ok {
+		<-call.done
+	}
+
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get after failed refresh = %d, %v; want 1, true", v, ok)
+	}
+	if got := cache.Stats().LoaderErrors; got != 1 {
+		t.Errorf("LoaderErrors = %d; want 1", got)
+	}
+
+	// The failed attempt pushed the refresh point out: no immediate retry.
+	if _, err := cache.Fetch("a", func() (int, error) {
+		t.Error("loader should not run again within the refresh period")
+		return 0, nil
+	}); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+}
+
+func TestCache_RefreshAfter_Disabled(t *testing.T) {
+	cache := New[string, int]()
+	cache.Set("a", 1)
+
+	e, _ := cache.memory.getEntry("a")
+	if e.refreshSec.Load() != 0 {
+		t.Error("refresh point should not be set without RefreshAfter")
+	}
+	if cache.memory.claimRefresh("a") {
+		t.Error("claimRefresh should be false without RefreshAfter")
+	}
+}
+
+func TestCache_RefreshAfter_SetResets(t *testing.T) {
+	cache := New[string, int](RefreshAfter(time.Minute))
+	cache.Set("a", 1)
+	makeStale(t, cache.memory, "a")
+	cache.Set("a", 2)
+
+	if cache.memory.claimRefresh("a") {
+		t.Error("a new value should not be due for refresh")
+	}
+}
+
+func TestTieredCache_RefreshAfter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, RefreshAfter(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	makeStale(t, cache.memory, "a")
+
+	release := make(chan struct{})
+	v, err := cache.Fetch(ctx, "a", func(ctx context.Context) (int, error) {
+		<-release
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 2, nil
+	})
+	if err != nil || v != 1 {
+		t.Fatalf("Fetch stale = %d, %v; want 1, nil", v, err)
+	}
+
+	// The reload outlives the request that triggered it.
+	cancel()
+	call, ok := cache.flights.Load("a")
+	if !ok {
+		t.Fatal("background reload should be registered in flights")
+	}
+	close(release)
+	<-call.done
+
+	if v, _, _ := cache.Get(context.Background(), "a"); v != 2 {
+		t.Errorf("Get after refresh = %d; want 2", v)
+	}
+	if v, _, _, _ := store.Get(context.Background(), "a"); v != 2 {
+		t.Errorf("store value after refresh = %d; want 2", v)
+	}
+}
@@ -0,0 +1,85 @@
+package fido
+
+import "math"
+
+// SetCapacity changes the maximum number of entries, or the weight budget for a
+// cache created with MaxWeight, keeping the warm working set. When shrinking,
+// entries are evicted in the usual S3-FIFO order until the cache fits.
+// Ghost history survives the resize, so recently evicted keys are still
+// readmitted straight to main. Values below 1 are ignored.
+func (c *Cache[K, V]) SetCapacity(n int) {
+	c.memory.setCapacity(n)
+}
+
+// SetCapacity changes the capacity of the memory tier as Cache.SetCapacity does.
+// The persistence layer is not affected.
+func (c *TieredCache[K, V]) SetCapacity(n int) {
+	c.memory.setCapacity(n)
+}
+
+// setCapacity recomputes every size newS3FIFO derives from the capacity and
+// evicts down to the new limit.
+func (c *s3fifo[K, V]) setCapacity(n int) {
+	if n < 1 {
+		return
+	}
+	if c.shards != nil {
+		// Split evenly, never leaving a shard empty.
+		k := len(c.shards)
+		for i, s := range c.shards {
+			share := n / k
+			if i < n%k {
+				share++
+			}
+			s.setCapacity(max(1, share))
+		}
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	// With MaxWeight, n is a weight budget: scale the expected entry count with it.
+	size, capacity := n, n
+	if c.weigher != nil {
+		capacity = min(n, math.MaxInt/1000)
+		size = max(1, int(float64(c.size)*float64(capacity)/float64(c.capacity)))
+	}
+
+	c.size = size
+	c.capacity = capacity
+	c.smallThresh = capacity * smallRatio(size) / 1000
+	c.ghostCap = size * ghostRatio(size) / 1000
+	c.ghostActive = c.ghostActive.resized(size, ghostFPRate)
+	c.ghostAging = c.ghostAging.resized(size, ghostFPRate)
+	c.resizeDeathRow(max(minDeathRowSize, size/768))
+
+	for c.totalWeight > c.capacity && c.small.len+c.main.len > 0 {
+		c.evictOne()
+	}
+}
+
+// resizeDeathRow moves pending evictions to a ring of n slots, oldest first.
+// If they do not all fit, the oldest are evicted. Must hold mutex.
+func (c *s3fifo[K, V]) resizeDeathRow(n int) {
+	if n == len(c.deathRow) {
+		return
+	}
+
+	pending := make([]*entry[K, V], 0, len(c.deathRow))
+	for i := range c.deathRow {
+		if e := c.deathRow[(c.deathRowPos+i)%len(c.deathRow)]; e != nil {
+			pending = append(pending, e)
+		}
+	}
+	for len(pending) > n {
+		old := pending[0]
+		pending = pending[1:]
+		old.setOnDeathRow(false)
+		c.evictEntry(old, c.expired(old))
+	}
+
+	c.deathRow = make([]*entry[K, V], n)
+	copy(c.deathRow, pending)
+	c.deathRowPos = len(pending) % n
+}
@@ -0,0 +1,157 @@
+package fido
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCache_SetCapacity_Shrink(t *testing.T) {
+	log := &removalLog[int, int]{}
+	cache := New[int, int](Size(1000), OnEvict(log.record))
+	for i := range 1000 {
+		cache.Set(i, i)
+	}
+	// Make the first 100 keys hot so they are promoted rather than evicted.
+	for range 3 {
+		for i := range 100 {
+			cache.Get(i)
+		}
+	}
+
+	cache.SetCapacity(200)
+
+	if got := cache.Len(); got > 200 {
+		t.Errorf("Len() = %d; want <= 200", got)
+	}
+	if got := log.count(ReasonEvicted); got == 0 {
+		t.Error("shrinking should report evictions to the listener")
+	}
+	for i := range 100 {
+		if _, ok := cache.Peek(i); !ok {
+			t.Errorf("hot key %d should survive the shrink", i)
+			break
+		}
+	}
+
+	m := cache.memory
+	if m.capacity != 200 || m.smallThresh != 200*smallRatio(200)/1000 || m.ghostCap != 200*ghostRatio(200)/1000 {
+		t.Errorf("capacity, smallThresh, ghostCap = %d, %d, %d; not recomputed for 200",
+			m.capacity, m.smallThresh, m.ghostCap)
+	}
+
+	// The cache keeps working within its new bounds.
+	for i := 1000; i < 2000; i++ {
+		cache.Set(i, i)
+	}
+	if got := cache.Len(); got > 200 {
+		t.Errorf("Len() after more inserts = %d; want <= 200", got)
+	}
+}
+
+func TestCache_SetCapacity_Grow(t *testing.T) {
+	cache := New[int, int](Size(100))
+	for i := range 300 {
+		cache.Set(i, i)
+	}
+
+	cache.SetCapacity(1000)
+	for i := 300; i < 1300; i++ {
+		cache.Set(i, i)
+	}
+	if got := cache.Len(); got < 900 {
+		t.Errorf("Len() = %d; want the cache to fill toward its new capacity of 1000", got)
+	}
+
+	cache.SetCapacity(0) // ignored
+	if cache.memory.capacity != 1000 {
+		t.Errorf("capacity = %d; SetCapacity(0) should be ignored", cache.memory.capacity)
+	}
+}
+
+func TestCache_SetCapacity_DeathRow(t *testing.T) {
+	cache := New[int, int](Size(16000)) // 20 death row slots
+	for i := range 100 {
+		cache.Set(i, i)
+	}
+	m := cache.memory
+	for i := range 20 {
+		e, _ := m.getEntry(i)
+		e.setFreqPeak(0, maxPeakFreq)
+		m.small.remove(e)
+		m.sendToDeathRow(e)
+	}
+
+	cache.SetCapacity(1000) // 8 death row slots
+
+	if got := len(m.deathRow); got != minDeathRowSize {
+		t.Fatalf("death row slots = %d; want %d", got, minDeathRowSize)
+	}
+	for i := range 12 {
+		if _, ok := m.entries.Load(i); ok {
+			t.Errorf("oldest death row entry %d should be evicted when the ring shrinks", i)
+		}
+	}
+	for i := 12; i < 20; i++ {
+		if e, ok := m.entries.Load(i); !ok || !e.onDeathRow() {
+			t.Errorf("newest death row entry %d should stay on death row", i)
+		}
+	}
+}
+
+func TestCache_SetCapacity_Weighted(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(1000), Size(100), Weigher(byteWeigher))
+	for i := range 100 {
+		cache.Set(strconv.Itoa(i), make([]byte, 10))
+	}
+
+	cache.SetCapacity(500)
+
+	if w := cache.memory.totalWeight; w > 500 {
+		t.Errorf("total weight = %d; want <= 500", w)
+	}
+	if got := cache.memory.size; got != 50 {
+		t.Errorf("expected entry count = %d; want 50, scaled with the budget", got)
+	}
+}
+
+func TestCache_SetCapacity_KeepsGhosts(t *testing.T) {
+	for _, n := range []int{100, 5000} {
+		cache := New[int, int](Size(1000))
+		m := cache.memory
+		var hashes []uint64
+		for i := range 50 {
+			h := m.hasher(i)
+			m.addToGhost(h, 1)
+			hashes = append(hashes, h)
+		}
+
+		cache.SetCapacity(n)
+
+		for _, h := range hashes {
+			if !m.ghostActive.Contains(h) {
+				t.Errorf("SetCapacity(%d) lost ghost history", n)
+				break
+			}
+		}
+	}
+}
+
+func TestBloomFilter_Resized(t *testing.T) {
+	b := newBloomFilter(1000, ghostFPRate)
+	for i := range 500 {
+		b.Add(hashInt64(int64(i)))
+	}
+
+	for _, capacity := range []int{10, 100, 1000, 10000} {
+		r := b.resized(capacity, ghostFPRate)
+		if want := newBloomFilter(capacity, ghostFPRate); len(r.data) != len(want.data) {
+			t.Errorf("resized(%d) has %d words; want %d", capacity, len(r.data), len(want.data))
+		}
+		for i := range 500 {
+			if !r.Contains(hashInt64(int64(i))) {
+				t.Errorf("resized(%d) lost member %d", capacity, i)
+				break
+			}
+		}
+	}
+}
@@ -2,11 +2,13 @@ package fido
 
 import (
 	"fmt"
+	"math"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestS3FIFO_BasicOperations(t *testing.T) {
@@ -153,7 +155,7 @@ func TestS3FIFO_TTL(t *testing.T) {
 	cache := newS3FIFO[string, int](&config{size: 10})
 
 	// Set item with past expiry
-	past := uint32(time.Now().Add(-1 * time.Second).Unix())
+	past := time.Now().Add(-1 * time.Second).UnixNano()
 	cache.set("expired", 42, past)
 
 	// Should not be retrievable
@@ -162,7 +164,7 @@ func TestS3FIFO_TTL(t *testing.T) {
 	}
 
 	// Set item with future expiry
-	future := uint32(time.Now().Add(1 * time.Hour).Unix())
+	future := time.Now().Add(1 * time.Hour).UnixNano()
 	cache.set("valid", 100, future)
 
 	// Should be retrievable
@@ -171,6 +173,21 @@ func TestS3FIFO_TTL(t *testing.T) {
 	}
 }
 
+func TestEntry_Size(t *testing.T) {
+	if got := unsafe.Sizeof(entry[int, int]{}); got != 64 {
+		t.Errorf("entry[int, int] is %d bytes; want one 64-byte cache line", got)
+	}
+}
+
+func TestEntry_SeqWrap(t *testing.T) {
+	var e entry[int, int]
+	e.seq.Store(math.MaxUint32 - 1)
+	e.storeValue(7)
+	if v, ok := e.loadValue(); !ok || v != 7 {
+		t.Errorf("loadValue() after wrap = %d, %v; want 7, true", v, ok)
+	}
+}
+
 func TestS3FIFO_Concurrent(t *testing.T) {
 	cache := newS3FIFO[int, int](&config{size: 1000})
 	var wg sync.WaitGroup
@@ -1631,17 +1648,17 @@ func TestS3FIFO_HashString_ShortStrings(t *testing.T) {
 	}
 }
 
-// TestS3FIFO_TimeToSec tests the timeToSec helper.
-func TestS3FIFO_TimeToSec(t *testing.T) {
+// TestS3FIFO_TimeToNano tests the timeToNano helper.
+func TestS3FIFO_TimeToNano(t *testing.T) {
 	// Zero time should return 0
-	if got := timeToSec(time.Time{}); got != 0 {
-		t.Errorf("timeToSec(zero) = %d; want 0", got)
+	if got := timeToNano(time.Time{}); got != 0 {
+		t.Errorf("timeToNano(zero) = %d; want 0", got)
 	}
 
-	// Non-zero time should return Unix seconds
+	// Non-zero time should return Unix nanoseconds
 	now := time.Now()
-	if got := timeToSec(now); got != uint32(now.Unix()) {
-		t.Errorf("timeToSec(now) = %d; want %d", got, now.Unix())
+	if got := timeToNano(now); got != now.UnixNano() {
+		t.Errorf("timeToNano(now) = %d; want %d", got, now.UnixNano())
 	}
 }
 
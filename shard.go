@@ -0,0 +1,106 @@
+package fido
+
+import "math/bits"
+
+// minShardSize is the fewest entries a shard may hold. Smaller shards give each
+// key too little ghost history and queue space, and hit rate drops noticeably
+// below that of an unsharded cache of the same total size.
+const minShardSize = 2048
+
+// Shards splits the memory cache into n independent S3-FIFO partitions by key
+// hash, each with its own lock, queues, ghost filters and death row, so writes
+// to different keys rarely contend. Capacity is divided evenly.
+// n is rounded up to a power of two, then reduced so that every shard holds at
+// least 2048 entries. Worth it only for write-heavy workloads on many cores.
+// Default 1 (unsharded).
+func Shards(n int) Option {
+	return func(c *config) { c.shards = n }
+}
+
+// newSharded builds a cache of independent shards behind a router. The router
+// holds only what is shared: the hasher, stats, clock, refresh setting, windows and tags.
+func newSharded[K comparable, V any](cfg *config, size, n int) *s3fifo[K, V] {
+	sub := *cfg
+	sub.shards = 0
+	sub.recordStats = false
+	sub.size = (size + n - 1) / n
+	if cfg.maxWeight > 0 {
+		sub.maxWeight = (cfg.maxWeight + uint64(n) - 1) / uint64(n)
+	}
+
+	c := &s3fifo[K, V]{
+		shards: make([]*s3fifo[K, V], n),
+		//nolint:gosec // G115: n is a power of two, so its length fits
+		shardShift:   uint8(64 - bits.Len(uint(n-1))),
+		refreshAfter: cfg.refreshAfter,
+		clock:        cfg.clock,
+		windows:      newWindowTable(),
+		tags:         &tagIndex[K]{},
+	}
+	if cfg.recordStats {
+		c.stats = newCacheStats()
+	}
+	for i := range c.shards {
+		s := newS3FIFO[K, V](&sub)
+		s.stats = c.stats
+		s.windows = c.windows
+		s.tags = c.tags
+		if s.idleID != 0 {
+			s.idleID = c.windows.id(int64(cfg.expireAfterAccess))
+		}
+		c.shards[i] = s
+	}
+	c.hasher = c.shards[0].hasher
+	return c
+}
+
+// shardCount returns the number of shards to use for n requested over size
+// entries, or 1 if the cache should not be sharded.
+func shardCount(n, size int) int {
+	if n <= 1 {
+		return 1
+	}
+	n = 1 << bits.Len(uint(n-1))
+	for n > 1 && size/n < minShardSize {
+		n /= 2
+	}
+	return n
+}
+
+// shard returns the partition that owns key, or c itself when unsharded.
+// The top hash bits pick the shard; the low bits index the ghost filters.
+func (c *s3fifo[K, V]) shard(key K) *s3fifo[K, V] {
+	if c.shards == nil {
+		return c
+	}
+	return c.shards[c.hasher(key)>>c.shardShift]
+}
+
+// parts returns the shards, or c itself when unsharded.
+func (c *s3fifo[K, V]) parts() []*s3fifo[K, V] {
+	if c.shards == nil {
+		return []*s3fifo[K, V]{c}
+	}
+	return c.shards
+}
+
+// rangeEntries calls fn for every entry in every shard until fn returns false.
+func (c *s3fifo[K, V]) rangeEntries(fn func(K, *entry[K, V]) bool) {
+	if c.shards == nil {
+		c.entries.Range(fn)
+		return
+	}
+	for _, s := range c.shards {
+		done := false
+		s.entries.Range(func(k K, e *entry[K, V]) bool {
+			if !fn(k, e) {
+				done = true
+				return false
+			}
+			return true
+		})
+		if done {
+			return
+		}
+	}
+}
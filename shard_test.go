@@ -0,0 +1,191 @@
+package fido
+
+import (
+	"bytes"
+	"maps"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardCount(t *testing.T) {
+	tests := []struct {
+		n, size, want int
+	}{
+		{0, 1 << 20, 1},
+		{1, 1 << 20, 1},
+		{3, 1 << 20, 4},
+		{8, 1 << 20, 8},
+		{8, 4 * minShardSize, 4},
+		{8, minShardSize, 1},
+	}
+	for _, tt := range tests {
+		if got := shardCount(tt.n, tt.size); got != tt.want {
+			t.Errorf("shardCount(%d, %d) = %d; want %d", tt.n, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestCache_Shards(t *testing.T) {
+	cache := New[int, int](Size(16384), Shards(4), RecordStats())
+	if got := len(cache.memory.shards); got != 4 {
+		t.Fatalf("shards = %d; want 4", got)
+	}
+
+	for i := range 1000 {
+		cache.Set(i, i)
+	}
+	if got := cache.Len(); got != 1000 {
+		t.Errorf("Len() = %d; want 1000", got)
+	}
+	for _, s := range cache.memory.shards {
+		if n := s.len(); n < 150 || n > 350 {
+			t.Errorf("shard holds %d of 1000 keys; want an even spread", n)
+		}
+	}
+	if v, ok := cache.Get(7); !ok || v != 7 {
+		t.Errorf("Get(7) = %d, %v; want 7, true", v, ok)
+	}
+	if v, ok := cache.Peek(8); !ok || v != 8 {
+		t.Errorf("Peek(8) = %d, %v; want 8, true", v, ok)
+	}
+	cache.Compute(9, func(old int, _ bool) (int, ComputeOp) { return old * 2, SetOp })
+	if v, _ := cache.Get(9); v != 18 {
+		t.Errorf("Get(9) after Compute = %d; want 18", v)
+	}
+	cache.Delete(7)
+	if _, ok := cache.Get(7); ok {
+		t.Error("Get(7) should miss after Delete")
+	}
+	if got := len(maps.Collect(cache.Range())); got != 999 {
+		t.Errorf("Range() yielded %d entries; want 999", got)
+	}
+	if got := cache.Stats().Hits; got != 2 {
+		t.Errorf("Stats().Hits = %d; want 2 shared across shards", got)
+	}
+
+	cache.Flush()
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Len() after Flush = %d; want 0", got)
+	}
+}
+
+func TestCache_Shards_HitRate(t *testing.T) {
+	const size = 16384
+	run := func(opts ...Option) float64 {
+		cache := New[uint64, int](append([]Option{Size(size)}, opts...)...)
+		zipf := rand.NewZipf(rand.New(rand.NewPCG(1, 2)), 1.01, 1, 1<<20)
+		var hits int
+		const n = 1_000_000
+		for range n {
+			k := zipf.Uint64()
+			if _, ok := cache.Get(k); ok {
+				hits++
+			} else {
+				cache.Set(k, 0)
+			}
+		}
+		return float64(hits) / n
+	}
+
+	base, sharded := run(), run(Shards(8))
+	t.Logf("hit rate: unsharded %.2f%%, 8 shards %.2f%%", base*100, sharded*100)
+	if base-sharded > 0.01 {
+		t.Errorf("sharded hit rate %.2f%% is more than 1 point below unsharded %.2f%%", sharded*100, base*100)
+	}
+}
+
+func TestCache_Shards_SetCapacity(t *testing.T) {
+	cache := New[int, int](Size(16384), Shards(4))
+	for i := range 16384 {
+		cache.Set(i, i)
+	}
+	cache.SetCapacity(4002)
+	if got := cache.Len(); got > 4002 {
+		t.Errorf("Len() = %d; want <= 4002", got)
+	}
+	total := 0
+	for _, s := range cache.memory.shards {
+		total += s.capacity
+	}
+	if total != 4002 {
+		t.Errorf("shard capacities sum to %d; want 4002", total)
+	}
+}
+
+func TestCache_Shards_SaveLoad(t *testing.T) {
+	src := New[int, int](Size(16384), Shards(4))
+	for i := range 20000 {
+		src.Set(i%9000, i)
+		src.Get(i % 300)
+	}
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	same := New[int, int](Size(16384), Shards(4))
+	if err := same.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i, s := range src.memory.shards {
+		d := same.memory.shards[i]
+		if !slicesEqualKeys(s.small, d.small) || !slicesEqualKeys(s.main, d.main) {
+			t.Errorf("shard %d queues not restored", i)
+		}
+	}
+
+	for _, dst := range []*Cache[int, int]{New[int, int](Size(16384)), New[int, int](Size(16384), Shards(2))} {
+		if err := dst.Load(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("Load into %d shards: %v", len(dst.memory.parts()), err)
+		}
+		if !maps.Equal(maps.Collect(src.Range()), maps.Collect(dst.Range())) {
+			t.Errorf("Load into %d shards lost entries", len(dst.memory.parts()))
+		}
+	}
+}
+
+func BenchmarkCache_SetParallel(b *testing.B) {
+	for _, n := range []int{1, 8} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) {
+			cache := New[int, int](Size(1<<16), Shards(n))
+			var seq atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(seq.Add(1)) << 32
+				for pb.Next() {
+					cache.Set(i, i) // every write inserts a new key
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCache_Shards_HitRate reports the Zipf hit rate of each shard count
+// at the gocachemark cache sizes, as the hitrate metric.
+func BenchmarkCache_Shards_HitRate(b *testing.B) {
+	for _, size := range []int{16, 32, 64, 128, 256} {
+		for _, n := range []int{1, 2, 4, 8} {
+			b.Run(strconv.Itoa(size)+"K/shards="+strconv.Itoa(n), func(b *testing.B) {
+				var rate float64
+				for range b.N {
+					cache := New[uint64, int](Size(size<<10), Shards(n))
+					zipf := rand.NewZipf(rand.New(rand.NewPCG(1, 2)), 1.01, 1, 1<<22)
+					hits := 0
+					const ops = 4_000_000
+					for range ops {
+						k := zipf.Uint64()
+						if _, ok := cache.Get(k); ok {
+							hits++
+						} else {
+							cache.Set(k, 0)
+						}
+					}
+					rate = float64(hits) * 100 / ops
+				}
+				b.ReportMetric(rate, "hitrate")
+			})
+		}
+	}
+}
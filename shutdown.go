@@ -0,0 +1,119 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrClosed is returned by TieredCache.SetAsync, and with WriteBack by every
+// TieredCache write, once Shutdown or Close has begun.
+var ErrClosed = errors.New("cache closed")
+
+// Shutdown stops accepting SetAsync writes, waits for the pending ones until
+// ctx is done, then stops the ActiveExpiration goroutine and closes the store.
+// Pending writes cover SetAsync, with or without WriteBehind, the stored
+// expiry updates of ExtendStoreExpiry and, with WriteBack, every dirty entry,
+// written as Sync does. Writes still queued when ctx is done are given up on,
+// and writes in flight are cancelled; the store is closed once they return.
+// The returned error joins an error for each write that failed or was given
+// up on, and the store's Close error. The memory tier remains usable.
+// Only the first call shuts down; later ones wait for it and return its error.
+func (c *TieredCache[K, V]) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() { c.shutdownErr = c.shutdown(ctx) })
+	return c.shutdownErr
+}
+
+func (c *TieredCache[K, V]) shutdown(ctx context.Context) error {
+	c.asyncMu.Lock()
+	c.closing.Store(true)
+	c.asyncMu.Unlock()
+
+	errs := c.queue.shutdown(ctx)
+	c.waitAsync(ctx)
+	if err := c.Sync(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	// Entries evicted during Sync may still be writing back.
+	c.asyncMu.Lock()
+	c.closed = true
+	c.asyncMu.Unlock()
+	c.waitAsync(ctx)
+	c.cancel()
+
+	c.asyncMu.Lock()
+	errs = append(errs, c.failed...)
+	c.failed = nil
+	c.asyncMu.Unlock()
+
+	c.memory.close()
+	if err := c.Store.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close persistence: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// waitAsync waits for the async writes registered so far. Once ctx is done,
+// it cancels them and waits for them to return.
+func (c *TieredCache[K, V]) waitAsync(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		c.async.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.cancel()
+		<-done
+	}
+}
+
+// asyncContext returns the context of a store write made on its own goroutine.
+// It keeps parent's values but not its cancellation, times out after
+// asyncTimeout, and is cancelled when Shutdown gives up on async writes.
+func (c *TieredCache[K, V]) asyncContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), asyncTimeout)
+	stop := context.AfterFunc(c.abort, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// beginAsync registers a store write made on its own goroutine, for Shutdown
+// to wait for. Returns false once Shutdown has begun.
+func (c *TieredCache[K, V]) beginAsync() bool {
+	c.asyncMu.Lock()
+	defer c.asyncMu.Unlock()
+	if c.closing.Load() {
+		return false
+	}
+	c.async.Add(1)
+	return true
+}
+
+// beginWriteBack is beginAsync for the write-back of an evicted entry, which
+// Shutdown still takes until its last Sync is done.
+func (c *TieredCache[K, V]) beginWriteBack() bool {
+	c.asyncMu.Lock()
+	defer c.asyncMu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.async.Add(1)
+	return true
+}
+
+// endAsync finishes a write registered by beginAsync. A write that fails
+// during Shutdown is reported by it.
+func (c *TieredCache[K, V]) endAsync(key K, err error) {
+	if err != nil {
+		c.asyncMu.Lock()
+		if c.closing.Load() {
+			c.failed = append(c.failed, fmt.Errorf("persistence store %v: %w", key, err))
+		}
+		c.asyncMu.Unlock()
+	}
+	c.async.Done()
+}
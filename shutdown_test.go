@@ -0,0 +1,190 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTieredCache_Shutdown_WaitsForAsync(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+
+	done := make(chan error)
+	go func() { done <- cache.Shutdown(ctx) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v before the async write finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := cache.SetAsync(ctx, "b", 2); !errors.Is(err, ErrClosed) {
+		t.Errorf("SetAsync during Shutdown = %v; want ErrClosed", err)
+	}
+
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if v, _, found, _ := store.mockStore.Get(ctx, "a"); !found || v != 1 { //nolint:errcheck // mock
+		t.Errorf("stored a = %d, %v; want 1 written before the store closed", v, found)
+	}
+	if !store.closed {
+		t.Error("Shutdown should close the store")
+	}
+	if _, ok := cache.memory.get("b"); ok {
+		t.Error("rejected SetAsync should not update memory")
+	}
+}
+
+func TestTieredCache_Shutdown_ReportsFailures(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	store.fails = 1
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+
+	done := make(chan error)
+	go func() { done <- cache.Shutdown(ctx) }()
+	for !cache.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(store.release)
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "persistence store a: set failed") {
+		t.Errorf("Shutdown = %v; want the failed write of a", err)
+	}
+}
+
+func TestTieredCache_Shutdown_Deadline(t *testing.T) {
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+	if err := cache.SetAsync(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	if err := cache.SetAsync(context.Background(), "b", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := cache.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v; want the queued write given up at the deadline", err)
+	}
+	for _, want := range []string{"persistence store a: context canceled", "persistence store b: context deadline exceeded"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Shutdown = %v; want it to report %q", err, want)
+		}
+	}
+	if _, _, found, _ := store.mockStore.Get(context.Background(), "b"); found { //nolint:errcheck // mock
+		t.Error("b should not be written after the deadline")
+	}
+	if err := cache.SetAsync(context.Background(), "c", 3); !errors.Is(err, ErrClosed) {
+		t.Errorf("SetAsync after Shutdown = %v; want ErrClosed", err)
+	}
+}
+
+// setCloseStore is a gateStore that records whether Close ran while a Set was in flight.
+type setCloseStore struct {
+	*gateStore
+	setting     atomic.Int32
+	closedInSet atomic.Bool
+}
+
+func (s *setCloseStore) Set(ctx context.Context, key string, value int, expiry time.Time) error {
+	s.setting.Add(1)
+	defer s.setting.Add(-1)
+	return s.gateStore.Set(ctx, key, value, expiry)
+}
+
+func (s *setCloseStore) Close() error {
+	s.closedInSet.Store(s.setting.Load() != 0)
+	return s.gateStore.Close()
+}
+
+func TestTieredCache_Shutdown_DeadlineCancelsAsync(t *testing.T) {
+	store := &setCloseStore{gateStore: newGateStore()}
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetAsync(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = cache.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "persistence store a: context canceled") {
+		t.Errorf("Shutdown = %v; want the cancelled write of a", err)
+	}
+	if store.closedInSet.Load() {
+		t.Error("Shutdown closed the store while a write was in flight")
+	}
+	if !store.closed {
+		t.Error("Shutdown should close the store")
+	}
+}
+
+// closeCountStore is a gateStore that counts its Close calls.
+type closeCountStore struct {
+	*gateStore
+	closes atomic.Int32
+}
+
+func (s *closeCountStore) Close() error {
+	s.closes.Add(1)
+	return s.gateStore.Close()
+}
+
+func TestTieredCache_Shutdown_Twice(t *testing.T) {
+	ctx := context.Background()
+	store := &closeCountStore{gateStore: newGateStore()}
+	store.fails = 1
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1, MaxRetries: -1})
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	done := make(chan error)
+	go func() { done <- cache.Shutdown(ctx) }()
+	for !cache.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(store.release)
+	first := <-done
+	if first == nil {
+		t.Fatal("Shutdown should report the failed write of a")
+	}
+
+	if err := cache.Shutdown(ctx); !errors.Is(err, first) {
+		t.Errorf("second Shutdown = %v; want the first result %v", err, first)
+	}
+	if err := cache.Close(); !errors.Is(err, first) {
+		t.Errorf("Close after Shutdown = %v; want the first result %v", err, first)
+	}
+	if n := store.closes.Load(); n != 1 {
+		t.Errorf("store closed %d times; want 1", n)
+	}
+	if n := len(cache.queue.failed); n != 0 {
+		t.Errorf("queue still holds %d reported failures", n)
+	}
+}
@@ -0,0 +1,220 @@
+package fido
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	// slideSteps is how finely a hit moves a sliding expiry: only once it would
+	// move by window/slideSteps (at most a second), so hot keys stay read-only.
+	slideSteps = 16
+
+	// storeSlideSteps is how often ExtendStoreExpiry rewrites a sliding entry to
+	// the store: once per window/storeSlideSteps of sliding.
+	storeSlideSteps = 4
+
+	// maxWindows bounds the distinct sliding windows a cache tracks, the most
+	// a window id in freqFlags can address.
+	maxWindows = windowMask
+)
+
+// ExpireAfterAccess makes entries written without a TTL expire once they have
+// gone unread and unwritten for d, rather than a fixed time after being written.
+// Every Get or Fetch hit pushes the expiry back out to d. Takes precedence over TTL.
+// To keep hits on hot keys from writing, the expiry is only moved once it would
+// move by d/16 or a second, whichever is less, so an entry may expire that much early.
+// In a TieredCache, entries loaded from persistence slide too.
+func ExpireAfterAccess(d time.Duration) Option {
+	return func(c *config) { c.expireAfterAccess = d }
+}
+
+// ExtendStoreExpiry makes a TieredCache carry sliding expiration over to the
+// persistence layer. Sliding entries are persisted with an expiry a quarter
+// window past their own, and rewritten in the background each time a hit moves
+// their expiry by another quarter window. Without it, the stored copy of a
+// sliding entry expires one window after it was last written.
+func ExtendStoreExpiry() Option {
+	return func(c *config) { c.extendStoreExpiry = true }
+}
+
+// SetExpireAfterAccess stores a value that expires once it has gone unread and
+// unwritten for d, as with ExpireAfterAccess. A zero or negative d means the
+// entry never expires.
+func (c *Cache[K, V]) SetExpireAfterAccess(key K, value V, d time.Duration) {
+	c.memory.stats.recordSet()
+	c.negative.del(key)
+	c.memory.set(key, value, slidingExpiry(d))
+}
+
+// SetExpireAfterAccess stores to memory first (always), then persistence,
+// with sliding expiration of d as Cache.SetExpireAfterAccess does.
+func (c *TieredCache[K, V]) SetExpireAfterAccess(ctx context.Context, key K, value V, d time.Duration) error {
+	if err := c.Store.ValidateKey(key); err != nil {
+		return err
+	}
+
+	c.memory.stats.recordSet()
+	if writeThrough, err := c.cacheWrite(key, value, slidingExpiry(d)); !writeThrough || err != nil {
+		return err
+	}
+
+	var expiry time.Time
+	if d > 0 {
+		expiry = c.slidingStoreExpiry(c.memory.now().Add(d), d)
+	}
+	if err := c.storeSet(ctx, key, value, expiry); err != nil {
+		c.memory.stats.recordStoreWrite(err)
+		return fmt.Errorf("persistence store failed: %w", err)
+	}
+	return nil
+}
+
+// slidingExpiry encodes a sliding window of d for s3fifo.set: a negative
+// expiry slides by its magnitude. Zero or negative d means no expiry.
+func slidingExpiry(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return -int64(d)
+}
+
+// storeExpiry returns the expiry to persist for a write whose memory expiry
+// is expiry. Entries without one slide in a cache with ExpireAfterAccess.
+func (c *TieredCache[K, V]) storeExpiry(expiry time.Time) time.Time {
+	if !expiry.IsZero() || c.idle <= 0 {
+		return expiry
+	}
+	return c.slidingStoreExpiry(c.memory.now().Add(c.idle), c.idle)
+}
+
+// slidingStoreExpiry pads a sliding entry's expiry for the store by the slack
+// between ExtendStoreExpiry rewrites.
+func (c *TieredCache[K, V]) slidingStoreExpiry(expiry time.Time, window time.Duration) time.Time {
+	if c.extendStore {
+		return expiry.Add(window / storeSlideSteps)
+	}
+	return expiry
+}
+
+// loadedExpiry returns the memory expiry for an entry loaded from persistence:
+// its stored one, or none so that it slides in a cache with ExpireAfterAccess.
+func (c *TieredCache[K, V]) loadedExpiry(expiry time.Time) int64 {
+	if c.idle > 0 {
+		return 0
+	}
+	return timeToNano(expiry)
+}
+
+// extendStored rewrites a sliding entry to persistence with a later expiry.
+// Called by s3fifo.slide when ExtendStoreExpiry is set.
+// Skipped once Shutdown has begun.
+func (c *TieredCache[K, V]) extendStored(key K, value V, expiry time.Time) {
+	if !c.beginAsync() {
+		return
+	}
+	go func() {
+		ctx, cancel := c.asyncContext(context.Background())
+		defer cancel()
+		err := c.Store.Set(ctx, key, value, expiry)
+		if err != nil {
+			c.memory.stats.recordStoreWrite(err)
+			slog.Warn("extending persisted expiry failed", "key", key, "error", err)
+		}
+		c.endAsync(key, err)
+	}()
+}
+
+// windowTable interns the sliding windows in use, so that an entry can name its
+// window with an id in the spare bits of freqFlags instead of a field of its own.
+type windowTable struct {
+	mu      sync.Mutex
+	ids     *xsync.Map[int64, uint32]
+	windows atomic.Pointer[[]int64] // window of id i at index i-1; copied on append
+}
+
+func newWindowTable() *windowTable {
+	t := &windowTable{ids: xsync.NewMap[int64, uint32]()}
+	t.windows.Store(&[]int64{})
+	return t
+}
+
+// id returns the id of window w, or 0 once maxWindows distinct windows are in use.
+func (t *windowTable) id(w int64) uint32 {
+	if id, ok := t.ids.Load(w); ok {
+		return id
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids.Load(w); ok {
+		return id
+	}
+	old := *t.windows.Load()
+	if len(old) >= maxWindows {
+		return 0
+	}
+	windows := append(old[:len(old):len(old)], w)
+	t.windows.Store(&windows)
+	//nolint:gosec // G115: bounded by maxWindows
+	id := uint32(len(windows))
+	t.ids.Store(w, id)
+	return id
+}
+
+// window returns the window with the given non-zero id.
+func (t *windowTable) window(id uint32) int64 {
+	return (*t.windows.Load())[id-1]
+}
+
+// resolveExpiry returns the absolute expiry for a write of expiryNano, as set
+// documents it, recording in ent whether and by how much it slides.
+func (c *s3fifo[K, V]) resolveExpiry(ent *entry[K, V], expiryNano int64) int64 {
+	var id uint32
+	switch {
+	case expiryNano < 0:
+		// Past maxWindows, a new window no longer slides but still expires.
+		id = c.windows.id(-expiryNano)
+		expiryNano = c.nowNano() - expiryNano
+	case expiryNano == 0 && c.idleID != 0:
+		id = c.idleID
+		expiryNano = c.nowNano() + c.windows.window(id)
+	}
+	ent.setWindow(id)
+	return expiryNano
+}
+
+// slide pushes a sliding entry's expiry back out to now plus its window, found
+// in flags. exp is the expiry get read at time now. The store is skipped until
+// the expiry would move by a slideSteps fraction of the window, at most a second.
+func (c *s3fifo[K, V]) slide(key K, ent *entry[K, V], flags uint32, exp, now int64) {
+	w := c.windows.window(flags >> windowShift & windowMask)
+	next := now + w
+	if next-exp < min(w/slideSteps, int64(time.Second)) || !ent.expiryNano.CompareAndSwap(exp, next) {
+		return
+	}
+	if c.wheel != nil {
+		c.wheel.reschedule(key, exp, next)
+	}
+	if step := max(w/storeSlideSteps, 1); c.onSlide != nil && exp/step != next/step {
+		if v, ok := ent.loadValue(); ok {
+			c.onSlide(key, v, time.Unix(0, next+step))
+		}
+	}
+}
+
+// setWindow sets the sliding window id, 0 for a fixed expiry, via CAS loop.
+func (e *entry[K, V]) setWindow(id uint32) {
+	for {
+		cur := e.freqFlags.Load()
+		updated := cur&^(windowMask<<windowShift) | id<<windowShift
+		if cur == updated || e.freqFlags.CompareAndSwap(cur, updated) {
+			return
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package fido
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// expiryOf returns key's expiry, failing the test if it is not cached.
+func expiryOf[K comparable, V any](t *testing.T, c *s3fifo[K, V], key K) int64 {
+	t.Helper()
+	e, ok := c.getEntry(key)
+	if !ok {
+		t.Fatalf("key %v not cached", key)
+	}
+	return e.expiryNano.Load()
+}
+
+func TestCache_ExpireAfterAccess(t *testing.T) {
+	cache := New[string, int](ExpireAfterAccess(time.Hour))
+	cache.Set("a", 1)
+	if exp := expiryOf(t, cache.memory, "a"); exp < nowNano()+int64(59*time.Minute) {
+		t.Fatalf("expiry = %v from now; want about an hour", time.Duration(exp-nowNano()))
+	}
+
+	// A hit pushes the expiry back out to the window.
+	e, _ := cache.memory.getEntry("a")
+	e.expiryNano.Store(nowNano() + int64(time.Minute))
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v; want 1, true", v, ok)
+	}
+	if exp := expiryOf(t, cache.memory, "a"); exp < nowNano()+int64(59*time.Minute) {
+		t.Errorf("expiry after hit = %v from now; want about an hour", time.Duration(exp-nowNano()))
+	}
+
+	// Hits that would move it by less than a second leave it alone.
+	almost := nowNano() + int64(time.Hour) - int64(100*time.Millisecond)
+	e.expiryNano.Store(almost)
+	cache.Get("a")
+	if exp := expiryOf(t, cache.memory, "a"); exp != almost {
+		t.Errorf("expiry moved by %v; want unchanged", time.Duration(exp-almost))
+	}
+}
+
+func TestCache_ExpireAfterAccess_Idle(t *testing.T) {
+	cache := New[string, int](ExpireAfterAccess(150 * time.Millisecond))
+	cache.Set("a", 1)
+	for range 3 {
+		time.Sleep(75 * time.Millisecond)
+		if _, ok := cache.Get("a"); !ok {
+			t.Fatal("entry read within its window should stay cached")
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("entry idle past its window should expire")
+	}
+}
+
+func TestCache_ExpireAfterAccess_OverridesTTL(t *testing.T) {
+	cache := New[string, int](TTL(time.Minute), ExpireAfterAccess(time.Hour))
+	cache.Set("a", 1)
+	if exp := expiryOf(t, cache.memory, "a"); exp < nowNano()+int64(59*time.Minute) {
+		t.Errorf("Set expiry = %v from now; want the hour window", time.Duration(exp-nowNano()))
+	}
+
+	// An explicit TTL stays fixed.
+	cache.SetTTL("b", 2, time.Minute)
+	before := expiryOf(t, cache.memory, "b")
+	cache.Get("b")
+	if exp := expiryOf(t, cache.memory, "b"); exp != before {
+		t.Error("hit should not extend an explicit TTL")
+	}
+}
+
+func TestCache_SetExpireAfterAccess(t *testing.T) {
+	cache := New[string, int](Shards(2), Size(8192))
+	cache.SetExpireAfterAccess("a", 1, time.Hour)
+	cache.SetExpireAfterAccess("b", 2, 2*time.Hour)
+	cache.Set("c", 3)
+
+	for key, window := range map[string]time.Duration{"a": time.Hour, "b": 2 * time.Hour} {
+		e, _ := cache.memory.getEntry(key)
+		e.expiryNano.Store(nowNano() + int64(time.Minute))
+		cache.Get(key)
+		if exp := expiryOf(t, cache.memory, key); exp < nowNano()+int64(window-time.Minute) {
+			t.Errorf("%s expiry after hit = %v from now; want about %v", key, time.Duration(exp-nowNano()), window)
+		}
+	}
+	if exp := expiryOf(t, cache.memory, "c"); exp != 0 {
+		t.Error("Set without ExpireAfterAccess should not expire")
+	}
+
+	// Overwriting with a fixed TTL stops the sliding.
+	cache.SetTTL("a", 1, time.Minute)
+	before := expiryOf(t, cache.memory, "a")
+	cache.Get("a")
+	if exp := expiryOf(t, cache.memory, "a"); exp != before {
+		t.Error("hit should not extend an entry rewritten with a TTL")
+	}
+}
+
+func TestCache_ExpireAfterAccess_SaveLoad(t *testing.T) {
+	src := New[string, int]()
+	src.SetExpireAfterAccess("a", 1, time.Hour)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	dst := New[string, int]()
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	e, _ := dst.memory.getEntry("a")
+	e.expiryNano.Store(nowNano() + int64(time.Minute))
+	dst.Get("a")
+	if exp := expiryOf(t, dst.memory, "a"); exp < nowNano()+int64(59*time.Minute) {
+		t.Error("loaded entry should keep sliding")
+	}
+}
+
+func TestTieredCache_ExpireAfterAccess(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, ExpireAfterAccess(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	_, stored, _, _ := store.Get(ctx, "a") //nolint:errcheck // mock
+	if d := time.Until(stored); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("stored expiry = %v from now; want the hour window", d)
+	}
+
+	// Without ExtendStoreExpiry, hits leave the store alone.
+	e, _ := cache.memory.getEntry("a")
+	e.expiryNano.Store(nowNano() + int64(time.Minute))
+	cache.Get(ctx, "a") //nolint:errcheck // memory hit
+	time.Sleep(50 * time.Millisecond)
+	if _, got, _, _ := store.Get(ctx, "a"); !got.Equal(stored) { //nolint:errcheck // mock
+		t.Error("hit should not rewrite the store without ExtendStoreExpiry")
+	}
+}
+
+func TestTieredCache_ExtendStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, ExpireAfterAccess(time.Hour), ExtendStoreExpiry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	_, stored, _, _ := store.Get(ctx, "a") //nolint:errcheck // mock
+	if d := time.Until(stored); d < 74*time.Minute {
+		t.Errorf("stored expiry = %v from now; want a quarter window of slack", d)
+	}
+
+	// A slide within the same quarter window is not written through.
+	e, _ := cache.memory.getEntry("a")
+	step := int64(15 * time.Minute)
+	now := nowNano()/step*step + int64(time.Minute)
+	e.expiryNano.Store(now + int64(59*time.Minute))
+	cache.memory.slide("a", e, e.freqFlags.Load(), now+int64(59*time.Minute), now)
+	if exp := e.expiryNano.Load(); exp != now+int64(time.Hour) {
+		t.Fatalf("expiry after slide = %v past now; want 1h", time.Duration(exp-now))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, got, _, _ := store.Get(ctx, "a"); !got.Equal(stored) { //nolint:errcheck // mock
+		t.Error("small slide should not rewrite the store")
+	}
+
+	// One crossing a quarter window is.
+	e.expiryNano.Store(nowNano() + int64(time.Minute))
+	cache.Get(ctx, "a") //nolint:errcheck // memory hit
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, got, _, _ := store.Get(ctx, "a") //nolint:errcheck // mock
+		if !got.Equal(stored) {
+			if d := time.Until(got); d < 74*time.Minute {
+				t.Errorf("extended store expiry = %v from now; want window plus slack", d)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("store expiry was not extended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWindowTable(t *testing.T) {
+	tab := newWindowTable()
+	a, b := tab.id(int64(time.Second)), tab.id(int64(time.Minute))
+	if a == 0 || b == 0 || a == b {
+		t.Fatalf("ids = %d, %d; want distinct non-zero", a, b)
+	}
+	if tab.id(int64(time.Second)) != a {
+		t.Error("same window should intern to the same id")
+	}
+	if w := tab.window(b); w != int64(time.Minute) {
+		t.Errorf("window(%d) = %v; want 1m", b, time.Duration(w))
+	}
+}
+
+func BenchmarkCache_GetExpireAfterAccess(b *testing.B) {
+	cache := New[string, int](ExpireAfterAccess(time.Hour))
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+		cache.Set(keys[i], i)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		cache.Get(keys[i&1023])
+	}
+}
@@ -0,0 +1,542 @@
+package fido
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Codec serializes keys and values in snapshots written by Save.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default snapshot Codec, using encoding/json.
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// SnapshotCodec sets the Codec that Save and Load use for keys and values.
+// Default JSONCodec.
+func SnapshotCodec(c Codec) Option {
+	return func(cfg *config) { cfg.codec = c }
+}
+
+// ErrBadSnapshot is returned by Load for input that is not a snapshot written by Save.
+var ErrBadSnapshot = errors.New("not a fido snapshot")
+
+const (
+	snapshotMagic   = "FIDO"
+	snapshotVersion = 1
+
+	maxSnapshotShards = 1 << 16
+
+	// maxSnapshotField bounds a single length-prefixed field, so a corrupt
+	// snapshot cannot make Load allocate unbounded memory.
+	maxSnapshotField = 1 << 30
+)
+
+// Save writes a snapshot of the cache to w: every live entry with its expiry,
+// queue and frequency counters, plus the ghost queue. Loading it into a cache
+// of the same size and shard count restores the same admission and eviction
+// decisions. Expired entries are left out. Concurrent writes may or may not be
+// included.
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.memory.save(w, c.codec)
+}
+
+// Load replaces the cache contents with a snapshot written by Save. Entries that
+// expired since are skipped, and if the snapshot holds more than fits, the cache
+// evicts down to capacity as usual. A snapshot from a cache with another shard
+// count keeps its entries but not its ghost queue. The cache is unchanged if
+// Load fails.
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	return c.memory.load(r, c.codec)
+}
+
+// Save writes a snapshot of the memory tier to w, as Cache.Save does.
+// The persistence layer is not included.
+func (c *TieredCache[K, V]) Save(w io.Writer) error {
+	return c.memory.save(w, c.codec)
+}
+
+// Load replaces the memory tier with a snapshot written by Save, as Cache.Load does.
+// The persistence layer is not affected, except that with WriteBack the dirty
+// entries being replaced are written back, as evicted ones are.
+func (c *TieredCache[K, V]) Load(r io.Reader) error {
+	return c.memory.load(r, c.codec)
+}
+
+// snapshotEntry is an entry copied out of the cache for Save, or decoded for Load.
+type snapshotEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiryNano int64
+	window     int64 // sliding window in nanoseconds, 0 for a fixed expiry
+	queue      Queue
+	freq       uint32
+	peakFreq   uint32
+	slot       int // death row slot, counted from the oldest
+}
+
+// snapshotState is everything a snapshot holds, copied under the mutex so that
+// encoding and I/O happen without it.
+type snapshotState[K comparable, V any] struct {
+	entries        []snapshotEntry[K, V] // small, then main, then death row, each oldest first
+	ghostActive    *bloomFilter
+	ghostAging     *bloomFilter
+	ghostFreqRng   ghostFreqRing
+	deathRowLen    int
+	warmupComplete bool
+}
+
+func (c *s3fifo[K, V]) save(w io.Writer, codec Codec) error {
+	parts := c.parts()
+	states := make([]*snapshotState[K, V], len(parts))
+	for i, s := range parts {
+		states[i] = s.capture()
+	}
+
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	sw.raw([]byte(snapshotMagic))
+	sw.uvarint(snapshotVersion)
+	sw.uvarint(uint64(len(states)))
+	for _, st := range states {
+		if err := writeState(sw, st, codec); err != nil {
+			return err
+		}
+	}
+
+	if sw.err == nil {
+		sw.err = sw.w.Flush()
+	}
+	if sw.err != nil {
+		return fmt.Errorf("write snapshot: %w", sw.err)
+	}
+	return nil
+}
+
+// writeState writes one shard's section. Write errors are kept in sw.err.
+func writeState[K comparable, V any](sw *snapshotWriter, st *snapshotState[K, V], codec Codec) error {
+	sw.bool(st.warmupComplete)
+	sw.bloom(st.ghostActive)
+	sw.bloom(st.ghostAging)
+	for i := range st.ghostFreqRng.hashes {
+		sw.uvarint(uint64(st.ghostFreqRng.hashes[i]))
+		sw.uvarint(uint64(st.ghostFreqRng.freqs[i]))
+	}
+	sw.uvarint(uint64(st.ghostFreqRng.pos))
+	sw.uvarint(uint64(st.deathRowLen))
+
+	sw.uvarint(uint64(len(st.entries)))
+	for i := range st.entries {
+		e := &st.entries[i]
+		if sw.err != nil {
+			break
+		}
+		k, err := codec.Marshal(e.key)
+		if err != nil {
+			return fmt.Errorf("encode key: %w", err)
+		}
+		v, err := codec.Marshal(e.value)
+		if err != nil {
+			return fmt.Errorf("encode value for %v: %w", e.key, err)
+		}
+		sw.bytes(k)
+		sw.bytes(v)
+		sw.varint(e.expiryNano)
+		sw.varint(e.window)
+		sw.uvarint(uint64(e.queue))
+		sw.uvarint(uint64(e.freq))
+		sw.uvarint(uint64(e.peakFreq))
+		sw.uvarint(uint64(e.slot))
+	}
+	return nil
+}
+
+// capture copies the cache state for save.
+func (c *s3fifo[K, V]) capture() *snapshotState[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Resizing to the current size copies the filters.
+	now := c.nowNano()
+	st := &snapshotState[K, V]{
+		entries:        make([]snapshotEntry[K, V], 0, c.small.len+c.main.len+len(c.deathRow)),
+		ghostActive:    c.ghostActive.resized(c.size, ghostFPRate),
+		ghostAging:     c.ghostAging.resized(c.size, ghostFPRate),
+		ghostFreqRng:   c.ghostFreqRng,
+		deathRowLen:    len(c.deathRow),
+		warmupComplete: c.warmupComplete,
+	}
+	add := func(e *entry[K, V], q Queue, slot int) {
+		exp := e.expiryNano.Load()
+		if exp != 0 && exp < now {
+			return
+		}
+		v, ok := e.loadValue()
+		if !ok {
+			return
+		}
+		var window int64
+		if id := e.freqFlags.Load() >> windowShift & windowMask; id != 0 {
+			window = c.windows.window(id)
+		}
+		st.entries = append(st.entries, snapshotEntry[K, V]{
+			key: e.key, value: v, expiryNano: exp, window: window, queue: q, freq: e.freq(), peakFreq: e.peakFreq(), slot: slot,
+		})
+	}
+	for e := c.small.head; e != nil; e = e.next {
+		add(e, QueueSmall, 0)
+	}
+	for e := c.main.head; e != nil; e = e.next {
+		add(e, QueueMain, 0)
+	}
+	for i := range c.deathRow {
+		if e := c.deathRow[(c.deathRowPos+i)%len(c.deathRow)]; e != nil {
+			add(e, QueueDeathRow, i)
+		}
+	}
+	return st
+}
+
+func (c *s3fifo[K, V]) load(r io.Reader, codec Codec) error {
+	states, err := decodeSnapshot[K, V](r, codec)
+	if err != nil {
+		return err
+	}
+	parts := c.parts()
+	if len(states) != len(parts) {
+		states = c.reshard(states)
+	}
+	for i, s := range parts {
+		s.install(states[i])
+	}
+	return nil
+}
+
+// reshard regroups the entries of a snapshot taken with another shard count
+// by the shard that owns them here. Ghost history cannot be split by key, so it
+// is dropped, and death row entries are packed oldest first.
+func (c *s3fifo[K, V]) reshard(states []*snapshotState[K, V]) []*snapshotState[K, V] {
+	out := make([]*snapshotState[K, V], len(c.parts()))
+	for i := range out {
+		out[i] = &snapshotState[K, V]{deathRowLen: -1}
+	}
+	for _, st := range states {
+		for _, se := range st.entries {
+			i := 0
+			if c.shards != nil {
+				i = int(c.hasher(se.key) >> c.shardShift)
+			}
+			out[i].entries = append(out[i].entries, se)
+			out[i].warmupComplete = out[i].warmupComplete || st.warmupComplete
+		}
+	}
+	return out
+}
+
+// install replaces this shard's contents with st. With WriteBack, the dirty
+// entries it replaces are staged for write-back first.
+func (c *s3fifo[K, V]) install(st *snapshotState[K, V]) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.flushLocked(true)
+
+	// Filters from a cache of another size are folded or tiled to this one's.
+	if st.ghostActive != nil {
+		c.ghostActive = st.ghostActive.resized(c.size, ghostFPRate)
+		c.ghostAging = st.ghostAging.resized(c.size, ghostFPRate)
+		c.ghostFreqRng = st.ghostFreqRng
+	}
+	c.warmupComplete = st.warmupComplete
+
+	now := c.nowNano()
+	var deathRow []*entry[K, V]
+	var slots []int
+	for i := range st.entries {
+		se := &st.entries[i]
+		if se.expiryNano != 0 && se.expiryNano < now {
+			continue
+		}
+		if _, dup := c.entries.Load(se.key); dup {
+			continue
+		}
+
+		ent := &entry[K, V]{key: se.key}
+		ent.storeValue(se.value)
+		ent.expiryNano.Store(se.expiryNano)
+		if c.wheel != nil && se.expiryNano != 0 {
+			c.wheel.add(se.key, se.expiryNano)
+		}
+		c.stampRefresh(ent)
+		ent.hash64 = c.hasher(se.key)
+		ent.weight = 1
+		if c.weigher != nil {
+			ent.weight = c.weigh(se.key, se.value)
+		}
+		if se.window > 0 && se.expiryNano != 0 {
+			ent.setWindow(c.windows.id(se.window))
+		}
+		ent.setFreqPeak(se.freq, se.peakFreq)
+		c.entries.Store(se.key, ent)
+
+		switch se.queue {
+		case QueueSmall:
+			ent.setInSmall(true)
+			c.small.pushBack(ent)
+		case QueueMain:
+			c.main.pushBack(ent)
+		case QueueDeathRow:
+			ent.setOnDeathRow(true)
+			deathRow = append(deathRow, ent)
+			slots = append(slots, se.slot)
+			continue
+		}
+		c.totalEntries.Add(1)
+		c.totalWeight += int(ent.weight)
+	}
+
+	// A ring of the saved size gets every entry back in its slot. Otherwise
+	// entries are packed oldest first, keeping the newest if they do not all fit.
+	if st.deathRowLen == len(c.deathRow) {
+		for i, e := range deathRow {
+			c.deathRow[slots[i]] = e
+		}
+		c.deathRowPos = 0
+	} else {
+		for len(deathRow) > len(c.deathRow) {
+			old := deathRow[0]
+			deathRow = deathRow[1:]
+			old.setOnDeathRow(false)
+			c.entries.Delete(old.key)
+		}
+		copy(c.deathRow, deathRow)
+		c.deathRowPos = len(deathRow) % len(c.deathRow)
+	}
+
+	for c.totalWeight > c.capacity && c.small.len+c.main.len > 0 {
+		c.evictOne()
+	}
+}
+
+// decodeSnapshot reads a whole snapshot, one state per shard, so that a corrupt
+// one is rejected before the cache is touched.
+func decodeSnapshot[K comparable, V any](r io.Reader, codec Codec) ([]*snapshotState[K, V], error) {
+	sr := &snapshotReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(sr.r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+	v := sr.uvarint()
+	if sr.err == nil && v != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", v)
+	}
+	n := sr.length(maxSnapshotShards)
+
+	var states []*snapshotState[K, V]
+	for range n {
+		st, err := readState[K, V](sr, codec)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, st)
+	}
+	if sr.err == nil && len(states) == 0 {
+		sr.err = errors.New("no shards")
+	}
+	if sr.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadSnapshot, sr.err)
+	}
+	return states, nil
+}
+
+// readState reads one shard's section. Read errors are kept in sr.err.
+func readState[K comparable, V any](sr *snapshotReader, codec Codec) (*snapshotState[K, V], error) {
+	st := &snapshotState[K, V]{}
+	st.warmupComplete = sr.bool()
+	st.ghostActive = sr.bloom()
+	st.ghostAging = sr.bloom()
+	for i := range st.ghostFreqRng.hashes {
+		st.ghostFreqRng.hashes[i] = sr.uint32()
+		st.ghostFreqRng.freqs[i] = sr.uint32()
+	}
+	//nolint:gosec // G115: ring position was written from a uint8
+	st.ghostFreqRng.pos = uint8(sr.uvarint())
+	st.deathRowLen = sr.length(maxSnapshotField)
+
+	n := sr.uvarint()
+	for i := uint64(0); i < n && sr.err == nil; i++ {
+		var se snapshotEntry[K, V]
+		k, v := sr.bytes(), sr.bytes()
+		se.expiryNano = sr.varint()
+		se.window = sr.varint()
+		//nolint:gosec // G115: queue was written from a Queue
+		se.queue = Queue(sr.uvarint())
+		se.freq = sr.uint32() & freqMask
+		se.peakFreq = sr.uint32() & peakFreqMask
+		se.slot = sr.length(maxSnapshotField)
+		if sr.err != nil {
+			break
+		}
+		if se.queue > QueueDeathRow {
+			return nil, fmt.Errorf("%w: unknown queue %d", ErrBadSnapshot, se.queue)
+		}
+		if se.queue == QueueDeathRow && se.slot >= st.deathRowLen {
+			return nil, fmt.Errorf("%w: death row slot %d out of range", ErrBadSnapshot, se.slot)
+		}
+		if err := codec.Unmarshal(k, &se.key); err != nil {
+			return nil, fmt.Errorf("decode key: %w", err)
+		}
+		if err := codec.Unmarshal(v, &se.value); err != nil {
+			return nil, fmt.Errorf("decode value for %v: %w", se.key, err)
+		}
+		st.entries = append(st.entries, se)
+	}
+	return st, nil
+}
+
+// snapshotWriter writes uvarint-encoded snapshot fields, keeping the first error.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+func (s *snapshotWriter) raw(b []byte) {
+	if s.err == nil {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+func (s *snapshotWriter) uvarint(x uint64) {
+	s.buf = binary.AppendUvarint(s.buf[:0], x)
+	s.raw(s.buf)
+}
+
+func (s *snapshotWriter) varint(x int64) {
+	s.buf = binary.AppendVarint(s.buf[:0], x)
+	s.raw(s.buf)
+}
+
+func (s *snapshotWriter) bool(b bool) {
+	if b {
+		s.uvarint(1)
+	} else {
+		s.uvarint(0)
+	}
+}
+
+// bytes writes a length-prefixed byte slice.
+func (s *snapshotWriter) bytes(b []byte) {
+	s.uvarint(uint64(len(b)))
+	s.raw(b)
+}
+
+func (s *snapshotWriter) bloom(b *bloomFilter) {
+	s.uvarint(uint64(b.k))
+	s.uvarint(uint64(b.entries))
+	s.uvarint(uint64(len(b.data)))
+	for _, w := range b.data {
+		s.buf = binary.LittleEndian.AppendUint64(s.buf[:0], w)
+		s.raw(s.buf)
+	}
+}
+
+// snapshotReader reads the fields snapshotWriter writes, keeping the first error.
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (s *snapshotReader) uvarint() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	x, err := binary.ReadUvarint(s.r)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+	return x
+}
+
+func (s *snapshotReader) varint() int64 {
+	if s.err != nil {
+		return 0
+	}
+	x, err := binary.ReadVarint(s.r)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+	return x
+}
+
+func (s *snapshotReader) uint32() uint32 {
+	x := s.uvarint()
+	if x > 1<<32-1 && s.err == nil {
+		s.err = fmt.Errorf("value %d overflows uint32", x)
+	}
+	//nolint:gosec // G115: range checked above
+	return uint32(x)
+}
+
+func (s *snapshotReader) bool() bool { return s.uvarint() != 0 }
+
+// length reads a length prefix, rejecting ones over limit.
+func (s *snapshotReader) length(limit uint64) int {
+	n := s.uvarint()
+	if n > limit && s.err == nil {
+		s.err = fmt.Errorf("field length %d exceeds %d", n, limit)
+	}
+	if s.err != nil {
+		return 0
+	}
+	return int(n) //nolint:gosec // G115: bounded by limit
+}
+
+func (s *snapshotReader) bytes() []byte {
+	n := s.length(maxSnapshotField)
+	if s.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		s.err = err
+	}
+	return b
+}
+
+// bloom reads a filter, which must have a power-of-two size of at least 64 bits
+// like the ones newBloomFilter makes.
+func (s *snapshotReader) bloom() *bloomFilter {
+	k := s.length(16)
+	entries := s.length(maxSnapshotField)
+	words := s.length(maxSnapshotField / 8)
+	if s.err == nil && (k == 0 || words == 0 || words&(words-1) != 0) {
+		s.err = errors.New("malformed ghost filter")
+	}
+	if s.err != nil {
+		return newBloomFilter(1, ghostFPRate)
+	}
+
+	b := &bloomFilter{data: make([]uint64, words), mask: uint64(words)*64 - 1, k: k, entries: entries}
+	buf := make([]byte, 8)
+	for i := range b.data {
+		if _, err := io.ReadFull(s.r, buf); err != nil {
+			s.err = err
+			break
+		}
+		b.data[i] = binary.LittleEndian.Uint64(buf)
+	}
+	return b
+}
@@ -0,0 +1,242 @@
+package fido
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+)
+
+// warmCache fills a cache past capacity with a skewed workload, so that it has
+// entries in small, main and on death row, and a populated ghost queue.
+func warmCache(t *testing.T, opts ...Option) *Cache[int, int] {
+	t.Helper()
+	cache := New[int, int](append([]Option{Size(1000)}, opts...)...)
+	for i := range 5000 {
+		k := i
+		if i%3 == 0 {
+			k = i % 200 // hot keys
+		}
+		cache.Set(k, k)
+		cache.Get(i % 200)
+	}
+
+	// Park a few entries on death row, leaving a gap in the ring.
+	m := cache.memory
+	var parked []int
+	for e := m.small.head; e != nil && len(parked) < 3; e = e.next {
+		parked = append(parked, e.key)
+	}
+	for _, k := range parked {
+		e, _ := m.getEntry(k)
+		e.setFreqPeak(0, maxPeakFreq)
+		m.small.remove(e)
+		m.sendToDeathRow(e)
+	}
+	cache.Delete(parked[1])
+	return cache
+}
+
+func TestCache_SaveLoad(t *testing.T) {
+	src := warmCache(t)
+	src.SetTTL(-1, 42, time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	dst := New[int, int](Size(1000))
+	dst.Set(99999, 1) // replaced by Load
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if src.Len() != dst.Len() {
+		t.Errorf("Len() = %d; want %d", dst.Len(), src.Len())
+	}
+	if _, ok := dst.Peek(99999); ok {
+		t.Error("Load should replace the existing contents")
+	}
+	var onDeathRow int
+	for k := range src.memory.entries.All() {
+		want, ok := src.GetEntry(k)
+		if !ok {
+			continue
+		}
+		got, ok := dst.GetEntry(k)
+		if !ok || got != want {
+			t.Fatalf("GetEntry(%d) = %+v, %v; want %+v", k, got, ok, want)
+		}
+		if got.Queue == QueueDeathRow {
+			onDeathRow++
+		}
+	}
+	if onDeathRow == 0 {
+		t.Error("test workload should leave entries on death row")
+	}
+	if !slicesEqualKeys(src.memory.small, dst.memory.small) || !slicesEqualKeys(src.memory.main, dst.memory.main) {
+		t.Error("queue order should be restored")
+	}
+
+	// Both caches now make the same decisions for the same workload.
+	for i := 5000; i < 8000; i++ {
+		k := i
+		if i%2 == 0 {
+			k = i % 300
+		}
+		src.Set(k, k)
+		dst.Set(k, k)
+		src.Get(i % 250)
+		dst.Get(i % 250)
+	}
+	if !maps.Equal(maps.Collect(src.Range()), maps.Collect(dst.Range())) {
+		t.Error("restored cache diverged from the original under the same workload")
+	}
+}
+
+func slicesEqualKeys(a, b entryList[int, int]) bool {
+	x, y := a.head, b.head
+	for x != nil && y != nil {
+		if x.key != y.key {
+			return false
+		}
+		x, y = x.next, y.next
+	}
+	return x == nil && y == nil
+}
+
+func TestCache_Load_SmallerCache(t *testing.T) {
+	src := warmCache(t)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	dst := New[int, int](Size(100))
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := dst.Len(); got > 100 {
+		t.Errorf("Len() = %d; want <= 100", got)
+	}
+	if got := len(dst.memory.deathRow); got != minDeathRowSize {
+		t.Errorf("death row slots = %d; want %d", got, minDeathRowSize)
+	}
+}
+
+func TestCache_Load_SkipsExpired(t *testing.T) {
+	src := New[string, string]()
+	src.Set("live", "a")
+	src.memory.set("stale", "b", nowNano()+int64(time.Second))
+
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// Expire "stale" between Save and Load by rewriting nothing but the clock's view.
+	e, _ := src.memory.getEntry("stale")
+	e.expiryNano.Store(nowNano() - 1)
+
+	dst := New[string, string]()
+	if err := dst.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v, ok := dst.Get("live"); !ok || v != "a" {
+		t.Errorf("Get(live) = %q, %v; want a, true", v, ok)
+	}
+}
+
+func TestCache_Load_Corrupt(t *testing.T) {
+	src := warmCache(t)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data := buf.Bytes()
+
+	dst := New[int, int]()
+	dst.Set(1, 1)
+
+	if err := dst.Load(bytes.NewReader([]byte("not a snapshot"))); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("Load(garbage) = %v; want ErrBadSnapshot", err)
+	}
+	if err := dst.Load(bytes.NewReader(data[:len(data)/2])); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("Load(truncated) = %v; want ErrBadSnapshot", err)
+	}
+	if v, ok := dst.Get(1); !ok || v != 1 {
+		t.Error("a failed Load should leave the cache unchanged")
+	}
+
+	bad := bytes.Clone(data)
+	bad[len(snapshotMagic)] = snapshotVersion + 1
+	if err := dst.Load(bytes.NewReader(bad)); err == nil {
+		t.Error("Load should reject an unknown snapshot version")
+	}
+}
+
+// upperCodec is a Codec that changes the encoding, to prove it is used.
+type upperCodec struct{ JSONCodec }
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	b, err := JSONCodec{}.Marshal(v)
+	return append([]byte("U"), b...), err
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 || data[0] != 'U' {
+		return errors.New("missing prefix")
+	}
+	return JSONCodec{}.Unmarshal(data[1:], v)
+}
+
+func TestCache_SaveLoad_Codec(t *testing.T) {
+	src := New[string, int](SnapshotCodec(upperCodec{}))
+	src.Set("a", 1)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := New[string, int]().Load(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("Load with a different codec should fail")
+	}
+	dst := New[string, int](SnapshotCodec(upperCodec{}))
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v, ok := dst.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v; want 1, true", v, ok)
+	}
+}
+
+func TestTieredCache_SaveLoad(t *testing.T) {
+	ctx := context.Background()
+	src, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	if err := src.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	store := newMockStore[string, int]()
+	dst, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v, ok := dst.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %d, %v; want 1, true", v, ok)
+	}
+	if n, _ := store.Len(ctx); n != 0 { //nolint:errcheck // mock store
+		t.Errorf("store has %d entries; Load should not write to persistence", n)
+	}
+}
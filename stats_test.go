@@ -0,0 +1,225 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Stats_Disabled(t *testing.T) {
+	cache := New[string, int]()
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("missing")
+
+	if got := cache.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() without RecordStats = %+v; want zero", got)
+	}
+}
+
+func TestCache_Stats_Basic(t *testing.T) {
+	cache := New[string, int](RecordStats())
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("missing")
+	cache.Delete("b")
+
+	if _, err := cache.Fetch("c", func() (int, error) { return 3, nil }); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if _, err := cache.Fetch("c", func() (int, error) { return 0, errors.New("unexpected load") }); err != nil {
+		t.Fatalf("Fetch hit: %v", err)
+	}
+	if _, err := cache.Fetch("d", func() (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Fatal("Fetch should return loader error")
+	}
+
+	s := cache.Stats()
+	if s.Hits != 3 {
+		t.Errorf("Hits = %d; want 3", s.Hits)
+	}
+	if s.Misses != 3 {
+		t.Errorf("Misses = %d; want 3", s.Misses)
+	}
+	if s.Sets != 2 {
+		t.Errorf("Sets = %d; want 2 (loader results are not explicit sets)", s.Sets)
+	}
+	if s.Deletes != 1 {
+		t.Errorf("Deletes = %d; want 1", s.Deletes)
+	}
+	if s.LoaderCalls != 2 {
+		t.Errorf("LoaderCalls = %d; want 2", s.LoaderCalls)
+	}
+	if s.LoaderErrors != 1 {
+		t.Errorf("LoaderErrors = %d; want 1", s.LoaderErrors)
+	}
+	if got := s.HitRate(); got != 0.5 {
+		t.Errorf("HitRate() = %v; want 0.5", got)
+	}
+}
+
+func TestCache_Stats_Evictions(t *testing.T) {
+	cache := New[int, int](Size(100), RecordStats())
+
+	for i := range 1000 {
+		cache.Set(i, i)
+	}
+
+	s := cache.Stats()
+	// Death row holds a few evicted entries without counting them as evicted yet.
+	if s.Evictions < 1000-100-int64(len(cache.memory.deathRow)) {
+		t.Errorf("Evictions = %d; want at least %d", s.Evictions, 1000-100-len(cache.memory.deathRow))
+	}
+	if s.Expirations != 0 {
+		t.Errorf("Expirations = %d; want 0", s.Expirations)
+	}
+}
+
+func TestCache_Stats_GhostReadmissions(t *testing.T) {
+	cache := New[int, int](Size(100), RecordStats())
+
+	// Fill past capacity so early keys land in the ghost queue.
+	for i := range 150 {
+		cache.Set(i, i)
+	}
+	// Re-insert the evicted keys: ghost hits go straight to main.
+	for i := range 50 {
+		cache.Set(i, i)
+	}
+
+	if s := cache.Stats(); s.GhostReadmissions == 0 {
+		t.Error("GhostReadmissions = 0; want > 0 after re-inserting evicted keys")
+	}
+}
+
+func TestCache_Stats_Resurrections(t *testing.T) {
+	cache := New[int, int](Size(100), RecordStats())
+
+	for i := range 100 {
+		cache.Set(i, i)
+	}
+	for range 5 {
+		for i := range 20 {
+			cache.Get(i)
+		}
+	}
+	for i := 100; i < 300; i++ {
+		cache.Set(i, i)
+	}
+
+	// Count how many hot keys are sitting on death row, then look them up.
+	onDeathRow := 0
+	for i := range 20 {
+		if e, ok := cache.memory.getEntry(i); ok && e.onDeathRow() {
+			onDeathRow++
+		}
+	}
+	for i := range 20 {
+		cache.Get(i)
+	}
+
+	if got := cache.Stats().Resurrections; got != int64(onDeathRow) {
+		t.Errorf("Resurrections = %d; want %d", got, onDeathRow)
+	}
+}
+
+func TestCache_Stats_Concurrent(t *testing.T) {
+	cache := New[int, int](RecordStats())
+	for i := range 100 {
+		cache.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for i := range 1000 {
+				cache.Get(i % 200)
+			}
+		})
+	}
+	wg.Wait()
+
+	s := cache.Stats()
+	if s.Hits != 4000 || s.Misses != 4000 {
+		t.Errorf("Hits, Misses = %d, %d; want 4000, 4000", s.Hits, s.Misses)
+	}
+}
+
+func TestTieredCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache, err := NewTiered[string, int](store, RecordStats())
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	if err := store.Set(ctx, "persisted", 1, time.Time{}); err != nil {
+		t.Fatalf("store.Set: %v", err)
+	}
+
+	// Memory miss, store hit.
+	if _, _, err := cache.Get(ctx, "persisted"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Memory hit.
+	if _, _, err := cache.Get(ctx, "persisted"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Memory miss, store miss.
+	if _, _, err := cache.Get(ctx, "missing"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Memory miss, store error.
+	store.setFailGet(true)
+	if _, _, err := cache.Get(ctx, "broken"); err == nil {
+		t.Fatal("Get should fail when store fails")
+	}
+	store.setFailGet(false)
+
+	// Store write failure.
+	store.setFailSet(true)
+	if err := cache.Set(ctx, "unwritable", 1); err == nil {
+		t.Fatal("Set should fail when store fails")
+	}
+	store.setFailSet(false)
+
+	s := cache.Stats()
+	if s.Hits != 1 || s.Misses != 3 {
+		t.Errorf("Hits, Misses = %d, %d; want 1, 3", s.Hits, s.Misses)
+	}
+	if s.StoreHits != 1 || s.StoreMisses != 1 || s.StoreErrors != 1 {
+		t.Errorf("StoreHits, StoreMisses, StoreErrors = %d, %d, %d; want 1, 1, 1",
+			s.StoreHits, s.StoreMisses, s.StoreErrors)
+	}
+	if s.StoreWriteErrors != 1 {
+		t.Errorf("StoreWriteErrors = %d; want 1", s.StoreWriteErrors)
+	}
+	if s.Sets != 1 {
+		t.Errorf("Sets = %d; want 1", s.Sets)
+	}
+	if s.StoreLatency <= 0 {
+		t.Errorf("StoreLatency = %v; want > 0", s.StoreLatency)
+	}
+}
+
+func BenchmarkCache_Get_Stats(b *testing.B) {
+	cache := New[int, int](RecordStats())
+	for i := range 10000 {
+		cache.Set(i, i)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(i % 10000)
+			i++
+		}
+	})
+}
@@ -29,3 +29,41 @@ type PrefixScanner[V any] interface {
 	// More expensive than Keys: loads and decodes values from storage.
 	Range(ctx context.Context, prefix string) iter.Seq2[string, V]
 }
+
+// PrefixDeleter is an optional interface for stores that can delete every key with a prefix
+// without listing the keys first. Only meaningful for Store[string, V].
+// TieredCache.DeletePrefix uses it when available, and falls back to PrefixScanner.Keys.
+type PrefixDeleter interface {
+	// DeletePrefix deletes every entry whose key starts with prefix and returns the number deleted.
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Tagger is an optional interface for stores that can record tags, which
+// TieredCache.SetWithTags requires so that InvalidateTag also removes entries
+// that have left memory or were written by another process.
+type Tagger[K comparable] interface {
+	// AddTags records that key carries tags, in addition to any it already has.
+	AddTags(ctx context.Context, key K, tags []string) error
+
+	// TaggedKeys returns the keys recorded with tag. It may include keys
+	// since deleted or expired.
+	TaggedKeys(ctx context.Context, tag string) ([]K, error)
+
+	// RemoveTag forgets that keys carry tag.
+	RemoveTag(ctx context.Context, tag string, keys []K) error
+}
+
+// BatchSetter is an optional interface for stores that can save several keys in one round trip.
+// The WriteBehind queue of TieredCache uses it when available, and falls back to Set per key.
+type BatchSetter[K comparable, V any] interface {
+	// SetMany saves vals. A key missing from expiries has no expiry.
+	SetMany(ctx context.Context, vals map[K]V, expiries map[K]time.Time) error
+}
+
+// BatchGetter is an optional interface for stores that can load several keys in one round trip.
+// TieredCache.GetMany and FetchMany use it when available, and fall back to Get per key.
+type BatchGetter[K comparable, V any] interface {
+	// GetMany returns the values and expiries of the keys that were found.
+	// Keys missing from vals were not found. A zero expiry means none.
+	GetMany(ctx context.Context, keys []K) (vals map[K]V, expiries map[K]time.Time, err error)
+}
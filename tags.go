@@ -0,0 +1,210 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// tagIndex maps tags to the keys written with them, for InvalidateTag.
+// A key keeps its tags until it leaves the cache: s3fifo queues removed keys
+// and drops them after unlocking, unless the key was written again meanwhile.
+type tagIndex[K comparable] struct {
+	mu     sync.Mutex
+	keys   map[string]map[K]struct{} // tag -> keys
+	tags   map[K][]string            // key -> tags
+	tagged atomic.Int64              // len(tags), read on the removal path without mu
+}
+
+// add records that key carries tags, in addition to any it already has, unless
+// present reports that it is not cached: it was turned away, or removed since
+// its write, and drop may already have run for it. Checking under mu orders it
+// against that drop.
+func (t *tagIndex[K]) add(key K, tags []string, present func(K) bool) {
+	if len(tags) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !present(key) {
+		return
+	}
+	if t.keys == nil {
+		t.keys = make(map[string]map[K]struct{})
+		t.tags = make(map[K][]string)
+	}
+	have := t.tags[key]
+	for _, tag := range tags {
+		if slices.Contains(have, tag) {
+			continue
+		}
+		have = append(have, tag)
+		keys := t.keys[tag]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			t.keys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.tags[key] = have
+	t.tagged.Store(int64(len(t.tags)))
+}
+
+// take forgets tag and returns the keys that carried it.
+func (t *tagIndex[K]) take(tag string) []K {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]K, 0, len(t.keys[tag]))
+	for key := range t.keys[tag] {
+		keys = append(keys, key)
+		t.untagLocked(key, tag)
+	}
+	delete(t.keys, tag)
+	t.tagged.Store(int64(len(t.tags)))
+	return keys
+}
+
+// drop forgets key's tags unless present reports that it is cached again.
+// Checking under mu orders it against an add that follows a fresh write.
+func (t *tagIndex[K]) drop(key K, present func(K) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tags, ok := t.tags[key]
+	if !ok || present(key) {
+		return
+	}
+	for _, tag := range tags {
+		delete(t.keys[tag], key)
+		if len(t.keys[tag]) == 0 {
+			delete(t.keys, tag)
+		}
+	}
+	delete(t.tags, key)
+	t.tagged.Store(int64(len(t.tags)))
+}
+
+// untagLocked removes tag from key's list. Must hold mu.
+func (t *tagIndex[K]) untagLocked(key K, tag string) {
+	tags := slices.DeleteFunc(t.tags[key], func(s string) bool { return s == tag })
+	if len(tags) == 0 {
+		delete(t.tags, key)
+		return
+	}
+	t.tags[key] = tags
+}
+
+func (t *tagIndex[K]) clear() {
+	t.mu.Lock()
+	t.keys, t.tags = nil, nil
+	t.tagged.Store(0)
+	t.mu.Unlock()
+}
+
+// queueUntag records a removed key whose tags unlock should drop. Must hold mutex.
+func (c *s3fifo[K, V]) queueUntag(key K) {
+	if c.tags.tagged.Load() != 0 {
+		c.untag = append(c.untag, key)
+	}
+}
+
+// dropTags forgets the tags of removed keys that were not written again.
+func (c *s3fifo[K, V]) dropTags(keys []K) {
+	for _, k := range keys {
+		c.tags.drop(k, c.present)
+	}
+}
+
+// present reports whether key has an entry, expired or not.
+func (c *s3fifo[K, V]) present(key K) bool {
+	_, ok := c.shard(key).entries.Load(key)
+	return ok
+}
+
+// SetWithTags stores a value with the default TTL, as Set does, and tags it so
+// that InvalidateTag of any of tags removes it. A key keeps its tags until it
+// leaves the cache, even if it is written again without them.
+func (c *Cache[K, V]) SetWithTags(key K, value V, tags ...string) {
+	c.Set(key, value)
+	c.memory.tags.add(key, tags, c.memory.present)
+}
+
+// InvalidateTag removes every entry tagged with tag, as Delete does.
+// Returns the number of keys that carried the tag.
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	keys := c.memory.tags.take(tag)
+	for _, k := range keys {
+		c.Delete(k)
+	}
+	return len(keys)
+}
+
+// SetWithTags stores to memory and persistence with the default TTL, as Set
+// does, and tags the entry so that InvalidateTag of any of tags removes it from
+// both. The tags are recorded in the store before the value, so a stored entry
+// is never missed by InvalidateTag. Requires a Store that implements Tagger;
+// otherwise returns an error wrapping errors.ErrUnsupported and stores nothing.
+func (c *TieredCache[K, V]) SetWithTags(ctx context.Context, key K, value V, tags ...string) error {
+	tagger, ok := c.Store.(Tagger[K])
+	if !ok {
+		return fmt.Errorf("store %T does not implement Tagger: %w", c.Store, errors.ErrUnsupported)
+	}
+	if err := c.Store.ValidateKey(key); err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		if err := tagger.AddTags(ctx, key, tags); err != nil {
+			return fmt.Errorf("persistence tag: %w", err)
+		}
+	}
+	if err := c.Set(ctx, key, value); err != nil {
+		return err
+	}
+	c.memory.tags.add(key, tags, c.memory.present)
+	return nil
+}
+
+// InvalidateTag removes every entry tagged with tag from memory and persistence,
+// then forgets the tag for those keys. Returns the number of keys removed.
+// On error, the tag is kept for the keys not yet removed, so a retry finishes the job.
+func (c *TieredCache[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	keys := c.memory.tags.take(tag)
+	for _, k := range keys {
+		c.memory.stats.recordDelete()
+		c.memory.del(k)
+		c.negative.del(k)
+	}
+
+	tagger, ok := c.Store.(Tagger[K])
+	if !ok {
+		return len(keys), nil
+	}
+	stored, err := tagger.TaggedKeys(ctx, tag)
+	if err != nil {
+		return len(keys), fmt.Errorf("persistence tagged keys: %w", err)
+	}
+	removed := make([]K, 0, len(stored))
+	var errs []error
+	for _, k := range stored {
+		c.memory.del(k)
+		c.negative.del(k)
+		if err := c.storeDelete(ctx, k); err != nil {
+			errs = append(errs, fmt.Errorf("persistence delete %v: %w", k, err))
+			continue
+		}
+		removed = append(removed, k)
+	}
+	if err := tagger.RemoveTag(ctx, tag, removed); err != nil {
+		errs = append(errs, fmt.Errorf("persistence untag: %w", err))
+	}
+
+	n := len(removed)
+	for _, k := range keys {
+		if !slices.Contains(stored, k) {
+			n++ // memory only: the store lost its record or never had the value
+		}
+	}
+	return n, errors.Join(errs...)
+}
@@ -0,0 +1,215 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_InvalidateTag(t *testing.T) {
+	cache := New[string, int](Shards(2), Size(8192))
+	cache.SetWithTags("a", 1, "tenant:1")
+	cache.SetWithTags("b", 2, "tenant:1", "tenant:2")
+	cache.SetWithTags("c", 3, "tenant:2")
+	cache.Set("d", 4)
+
+	if n := cache.InvalidateTag("tenant:1"); n != 2 {
+		t.Errorf("InvalidateTag(tenant:1) = %d; want 2", n)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok := cache.Get(k); ok {
+			t.Errorf("Get(%s) should miss after InvalidateTag", k)
+		}
+	}
+	for _, k := range []string{"c", "d"} {
+		if _, ok := cache.Get(k); !ok {
+			t.Errorf("Get(%s) should still hit", k)
+		}
+	}
+	if n := cache.InvalidateTag("tenant:1"); n != 0 {
+		t.Errorf("second InvalidateTag(tenant:1) = %d; want 0", n)
+	}
+	if n := cache.InvalidateTag("tenant:2"); n != 1 {
+		t.Errorf("InvalidateTag(tenant:2) = %d; want 1 once b is gone", n)
+	}
+}
+
+func TestCache_InvalidateTag_KeepsTagsOnOverwrite(t *testing.T) {
+	cache := New[string, int]()
+	cache.SetWithTags("a", 1, "x")
+	cache.Set("a", 2)
+	if n := cache.InvalidateTag("x"); n != 1 {
+		t.Errorf("InvalidateTag(x) = %d; want 1 for an overwritten key", n)
+	}
+}
+
+func TestCache_Tags_DroppedOnRemoval(t *testing.T) {
+	clock := newFakeClock()
+	cache := New[int, int](Size(100), WithClock(clock), ActiveExpiration())
+	defer cache.Close()
+
+	// Evicted.
+	for i := range 1000 {
+		cache.SetWithTags(i, i, "t"+strconv.Itoa(i%3))
+	}
+	if n := int(cache.memory.tags.tagged.Load()); n > 200 {
+		t.Errorf("%d keys tagged after evictions; want about the cache size", n)
+	}
+
+	// Deleted and expired.
+	cache.Flush()
+	cache.SetWithTags(1, 1, "t")
+	cache.SetWithTags(2, 2, "t")
+	cache.memory.setExpiry(2, mustEntry(t, cache.memory, 2), clock.Now().Add(time.Minute).UnixNano())
+	cache.Delete(1)
+	clock.Advance(2 * time.Minute)
+	cache.memory.sweep(cache.memory.nowNano())
+	if n := cache.memory.tags.tagged.Load(); n != 0 {
+		t.Errorf("%d keys tagged after delete and expiry; want 0", n)
+	}
+}
+
+func TestTagIndex_DropSkipsRewritten(t *testing.T) {
+	var idx tagIndex[string]
+	present := func(string) bool { return true }
+	idx.add("a", []string{"x"}, present)
+	idx.drop("a", present)
+	if keys := idx.take("x"); !slices.Equal(keys, []string{"a"}) {
+		t.Errorf("take(x) = %v; want [a] kept for a key written again", keys)
+	}
+}
+
+func TestCache_SetWithTags_Rejected(t *testing.T) {
+	cache := New[string, int](MaxWeight(10), Weigher(func(string, int) uint64 { return 100 }))
+	cache.SetWithTags("a", 1, "x")
+	if n := cache.InvalidateTag("x"); n != 0 {
+		t.Errorf("InvalidateTag(x) = %d; want 0 for a key that was turned away", n)
+	}
+	if n := cache.memory.tags.tagged.Load(); n != 0 {
+		t.Errorf("%d keys tagged; want 0", n)
+	}
+}
+
+// mustEntry returns key's entry, failing the test if it is not cached.
+func mustEntry[K comparable, V any](t *testing.T, c *s3fifo[K, V], key K) *entry[K, V] {
+	t.Helper()
+	e, ok := c.getEntry(key)
+	if !ok {
+		t.Fatalf("key %v not cached", key)
+	}
+	return e
+}
+
+// tagStore adds a Tagger to mockStore.
+type tagStore[K comparable, V any] struct {
+	*mockStore[K, V]
+	mu   sync.Mutex
+	tags map[string][]K
+}
+
+func newTagStore[K comparable, V any]() *tagStore[K, V] {
+	return &tagStore[K, V]{mockStore: newMockStore[K, V](), tags: make(map[string][]K)}
+}
+
+func (s *tagStore[K, V]) AddTags(_ context.Context, key K, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		s.tags[tag] = append(s.tags[tag], key)
+	}
+	return nil
+}
+
+func (s *tagStore[K, V]) TaggedKeys(_ context.Context, tag string) ([]K, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tags[tag]), nil
+}
+
+func (s *tagStore[K, V]) RemoveTag(_ context.Context, tag string, keys []K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[tag] = slices.DeleteFunc(s.tags[tag], func(k K) bool { return slices.Contains(keys, k) })
+	return nil
+}
+
+func TestTieredCache_InvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	store := newTagStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetWithTags(ctx, "a", 1, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetWithTags(ctx, "b", 2, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "c", 3); err != nil {
+		t.Fatal(err)
+	}
+	cache.memory.del("b") // evicted from memory, still persisted
+
+	n, err := cache.InvalidateTag(ctx, "x")
+	if err != nil || n != 2 {
+		t.Fatalf("InvalidateTag(x) = %d, %v; want 2, nil", n, err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok, _ := cache.Get(ctx, k); ok { //nolint:errcheck // mock
+			t.Errorf("Get(%s) should miss in both tiers after InvalidateTag", k)
+		}
+	}
+	if _, ok, _ := cache.Get(ctx, "c"); !ok { //nolint:errcheck // mock
+		t.Error("untagged entry should remain")
+	}
+	if keys, _ := store.TaggedKeys(ctx, "x"); len(keys) != 0 { //nolint:errcheck // mock
+		t.Errorf("store still records %v under x", keys)
+	}
+}
+
+func TestTieredCache_InvalidateTag_DeleteFails(t *testing.T) {
+	ctx := context.Background()
+	store := newTagStore[string, int]()
+	cache, err := NewTiered[string, int](store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetWithTags(ctx, "a", 1, "x"); err != nil {
+		t.Fatal(err)
+	}
+	failing := &failDeleteStore[string, int]{tagStore: store}
+	cache.Store = failing
+	if _, err := cache.InvalidateTag(ctx, "x"); err == nil {
+		t.Fatal("InvalidateTag should report the failed delete")
+	}
+	if keys, _ := store.TaggedKeys(ctx, "x"); !slices.Equal(keys, []string{"a"}) { //nolint:errcheck // mock
+		t.Errorf("store records %v under x; want [a] kept for a retry", keys)
+	}
+}
+
+// failDeleteStore is a tagStore whose Delete always fails.
+type failDeleteStore[K comparable, V any] struct {
+	*tagStore[K, V]
+}
+
+func (*failDeleteStore[K, V]) Delete(context.Context, K) error {
+	return errors.New("delete failed")
+}
+
+func TestTieredCache_SetWithTags_Unsupported(t *testing.T) {
+	cache, err := NewTiered[string, int](newMockStore[string, int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetWithTags(context.Background(), "a", 1, "x"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetWithTags = %v; want errors.ErrUnsupported", err)
+	}
+	if _, ok := cache.memory.get("a"); ok {
+		t.Error("unsupported SetWithTags should store nothing")
+	}
+}
@@ -0,0 +1,162 @@
+package fido
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func byteWeigher(_ string, v []byte) uint64 { return uint64(len(v)) }
+
+func TestCache_MaxWeight(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(1000), Size(100), Weigher(byteWeigher))
+
+	for i := range 500 {
+		cache.Set(strconv.Itoa(i), make([]byte, 10+i%40))
+	}
+
+	if w := cache.memory.totalWeight; w > 1000 {
+		t.Errorf("total weight = %d; want <= 1000", w)
+	}
+	if w := cache.memory.small.weight + cache.memory.main.weight; w != cache.memory.totalWeight {
+		t.Errorf("queue weights sum to %d; total weight is %d", w, cache.memory.totalWeight)
+	}
+	if cache.Len() == 0 {
+		t.Error("cache should not be empty")
+	}
+}
+
+func TestCache_MaxWeight_DefaultWeigher(t *testing.T) {
+	// Without a Weigher every entry weighs 1, so MaxWeight acts like Size.
+	cache := New[int, int](MaxWeight(50), Size(1000))
+
+	for i := range 500 {
+		cache.Set(i, i)
+	}
+
+	if got := cache.Len(); got > 50 {
+		t.Errorf("Len() = %d; want <= 50", got)
+	}
+}
+
+func TestCache_MaxWeight_Oversized(t *testing.T) {
+	log := &removalLog[string, []byte]{}
+	cache := New[string, []byte](MaxWeight(100), Weigher(byteWeigher), OnEvict(log.record), RecordStats())
+
+	cache.Set("small", make([]byte, 10))
+	cache.Set("huge", make([]byte, 101))
+
+	if _, ok := cache.Get("huge"); ok {
+		t.Error("entry heavier than MaxWeight should be rejected")
+	}
+	if _, ok := cache.Get("small"); !ok {
+		t.Error("rejecting an oversized entry should not evict others")
+	}
+	if got := log.count(ReasonEvicted); got != 1 {
+		t.Errorf("evicted notifications = %d; want 1", got)
+	}
+	if got := cache.Stats().Evictions; got != 1 {
+		t.Errorf("Evictions = %d; want 1", got)
+	}
+
+	// Growing an existing entry past the budget removes it.
+	cache.Set("small", make([]byte, 200))
+	if _, ok := cache.Get("small"); ok {
+		t.Error("entry grown past MaxWeight should be removed")
+	}
+	if cache.Len() != 0 || cache.memory.totalWeight != 0 {
+		t.Errorf("Len, weight = %d, %d; want 0, 0", cache.Len(), cache.memory.totalWeight)
+	}
+	if got := log.count(ReasonReplaced); got != 1 {
+		t.Errorf("replaced notifications = %d; want 1", got)
+	}
+}
+
+func TestCache_MaxWeight_Reweigh(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(100), Weigher(byteWeigher))
+
+	for i := range 5 {
+		cache.Set(strconv.Itoa(i), make([]byte, 10))
+	}
+	if got := cache.memory.totalWeight; got != 50 {
+		t.Fatalf("total weight = %d; want 50", got)
+	}
+
+	// Growing one entry adjusts the total and evicts others to make room.
+	cache.Set("0", make([]byte, 80))
+	if got := cache.memory.totalWeight; got > 100 {
+		t.Errorf("total weight after growing = %d; want <= 100", got)
+	}
+	if v, ok := cache.Get("0"); ok && len(v) != 80 {
+		t.Errorf("Get(0) len = %d; want 80", len(v))
+	}
+
+	// Shrinking it gives the weight back.
+	cache.Set("0", make([]byte, 1))
+	before := cache.memory.totalWeight
+	cache.Set("0", make([]byte, 2))
+	if got := cache.memory.totalWeight; got != before+1 {
+		t.Errorf("total weight = %d; want %d", got, before+1)
+	}
+}
+
+func TestCache_MaxWeight_ZeroWeight(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(10), Weigher(byteWeigher))
+
+	for i := range 100 {
+		cache.Set(strconv.Itoa(i), nil)
+	}
+
+	if got := cache.Len(); got > 10 {
+		t.Errorf("Len() = %d; want <= 10 (zero weight counts as 1)", got)
+	}
+}
+
+func TestCache_MaxWeight_Delete(t *testing.T) {
+	cache := New[string, []byte](MaxWeight(100), Weigher(byteWeigher))
+
+	cache.Set("a", make([]byte, 30))
+	cache.Set("b", make([]byte, 20))
+	cache.Delete("a")
+
+	if got := cache.memory.totalWeight; got != 20 {
+		t.Errorf("total weight after delete = %d; want 20", got)
+	}
+	cache.Flush()
+	if got := cache.memory.totalWeight; got != 0 {
+		t.Errorf("total weight after flush = %d; want 0", got)
+	}
+}
+
+func TestCache_Weigher_TypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New with mismatched Weigher should panic")
+		}
+	}()
+	New[string, int](MaxWeight(10), Weigher(func(string, string) uint64 { return 1 }))
+}
+
+func TestTieredCache_MaxWeight(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, []byte]()
+	cache, err := NewTiered[string, []byte](store, MaxWeight(100), Weigher(byteWeigher))
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	defer func() { _ = cache.Close() }() //nolint:errcheck // Test cleanup
+
+	for i := range 20 {
+		if err := cache.Set(ctx, strconv.Itoa(i), make([]byte, 10)); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	if got := cache.memory.totalWeight; got > 100 {
+		t.Errorf("memory weight = %d; want <= 100", got)
+	}
+	// Evicted entries are still served from the store.
+	if _, found, err := cache.Get(ctx, "0"); err != nil || !found {
+		t.Errorf("Get(0) = found %v, err %v; want found from store", found, err)
+	}
+}
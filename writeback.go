@@ -0,0 +1,263 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// WriteBack makes TieredCache writes update memory only, marking the entry
+// dirty. A dirty entry is written to the store when S3-FIFO evicts it, by Sync,
+// and by Shutdown or Close, so a value rewritten many times while cached costs
+// one store write. Until then a Get that misses memory still sees a value being
+// written back. Delete, DeletePrefix, InvalidateTag and Flush remove a key from
+// both tiers, so they discard its dirty value along with the stored one; expired
+// dirty entries are never written. A write-back that fails is logged and counted
+// in Stats.StoreWriteErrors, and one that fails after eviction loses the value,
+// as does a crash before Sync. Store errors are not returned by Set, SetMany or
+// the conditional writes; once Shutdown has begun, they return ErrClosed and
+// change nothing. Takes precedence over WriteBehind. Ignored by Cache.
+func WriteBack() Option {
+	return func(c *config) { c.writeBack = true }
+}
+
+// dirtyValue is a WriteBack value staged for a store write, with the expiry its
+// entry had.
+type dirtyValue[V any] struct {
+	value  V
+	expiry int64 // nanoseconds since Unix epoch, 0 for none
+	window int64 // sliding window in nanoseconds, 0 for a fixed expiry
+}
+
+// dirty reports whether the entry holds a value not yet written back.
+func (e *entry[K, V]) dirty() bool { return e.freqFlags.Load()&dirtyBit != 0 }
+
+// setDirty sets or clears the dirty flag via CAS loop, as get may be bumping
+// the counters concurrently. Must be called under mutex.
+func (e *entry[K, V]) setDirty(v bool) {
+	for {
+		cur := e.freqFlags.Load()
+		updated := cur &^ dirtyBit
+		if v {
+			updated |= dirtyBit
+		}
+		if cur == updated || e.freqFlags.CompareAndSwap(cur, updated) {
+			return
+		}
+	}
+}
+
+// markDirty flags key's entry as not yet written back, after a write of value
+// with expiryNano as set takes it. If the entry was evicted in between, value
+// is staged instead, and the caller must write back what is returned, if not nil.
+func (c *s3fifo[K, V]) markDirty(key K, value V, expiryNano int64) *dirtyValue[V] {
+	if c.shards != nil {
+		return c.shard(key).markDirty(key, value, expiryNano)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ent, ok := c.entries.Load(key); ok {
+		ent.setDirty(true)
+		return nil
+	}
+	dv := &dirtyValue[V]{value: value, expiry: expiryNano}
+	switch {
+	case expiryNano < 0:
+		dv.window = -expiryNano
+	case expiryNano == 0 && c.idleID != 0:
+		dv.window = c.windows.window(c.idleID)
+	}
+	if dv.window != 0 {
+		dv.expiry = c.nowNano() + dv.window
+	}
+	return c.stage(key, dv)
+}
+
+// stageEntry clears e's dirty flag and stages its value, as stage does.
+// Must hold mutex.
+func (c *s3fifo[K, V]) stageEntry(e *entry[K, V]) *dirtyValue[V] {
+	e.setDirty(false)
+	v := e.currentValue()
+	dv := &dirtyValue[V]{value: v, expiry: e.expiryNano.Load()}
+	if id := e.freqFlags.Load() >> windowShift & windowMask; id != 0 {
+		dv.window = c.windows.window(id)
+	}
+	return c.stage(e.key, dv)
+}
+
+// stage makes dv the next value to write back for key, replacing any staged
+// before it. Returns dv if the caller must write it, or nil if a write of key
+// is already running and will pick dv up when it finishes.
+func (c *s3fifo[K, V]) stage(key K, dv *dirtyValue[V]) *dirtyValue[V] {
+	if _, running := c.writing.LoadAndStore(key, dv); running {
+		return nil
+	}
+	return dv
+}
+
+// written retires dv once its write has finished, returning the value staged
+// for key meanwhile, if any, for the same writer to write next.
+func (c *s3fifo[K, V]) written(key K, dv *dirtyValue[V]) *dirtyValue[V] {
+	var next *dirtyValue[V]
+	c.writing.Compute(key, func(cur *dirtyValue[V], loaded bool) (*dirtyValue[V], xsync.ComputeOp) {
+		switch {
+		case !loaded:
+			return nil, xsync.CancelOp // discarded by Delete or Flush
+		case cur == dv:
+			return nil, xsync.DeleteOp
+		}
+		next = cur
+		return cur, xsync.CancelOp
+	})
+	return next
+}
+
+// staged returns the unexpired value being written back for key, if any.
+func (c *s3fifo[K, V]) staged(key K) (*dirtyValue[V], bool) {
+	if c.writing == nil {
+		return nil, false
+	}
+	dv, ok := c.writing.Load(key)
+	if !ok || dv.expiry != 0 && dv.expiry < c.nowNano() {
+		return nil, false
+	}
+	return dv, true
+}
+
+// stageDirty stages the value of every dirty entry for Sync, returning the keys
+// and values the caller must write back. Expired entries are just cleared.
+func (c *s3fifo[K, V]) stageDirty() (keys []K, vals []*dirtyValue[V]) {
+	for _, s := range c.parts() {
+		s.entries.Range(func(k K, e *entry[K, V]) bool {
+			if !e.dirty() {
+				return true
+			}
+			s.mu.Lock()
+			// Recheck under the lock: e may have been evicted, or recycled for another key.
+			if cur, ok := s.entries.Load(k); ok && cur == e && e.dirty() {
+				if s.expired(e) {
+					e.setDirty(false)
+				} else if dv := s.stageEntry(e); dv != nil {
+					keys = append(keys, k)
+					vals = append(vals, dv)
+				}
+			}
+			s.mu.Unlock()
+			return true
+		})
+	}
+	return keys, vals
+}
+
+// redirty flags key's entry as dirty again after a failed Sync write, if it is
+// still cached.
+func (c *s3fifo[K, V]) redirty(key K) {
+	if c.shards != nil {
+		c.shard(key).redirty(key)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ent, ok := c.entries.Load(key); ok {
+		ent.setDirty(true)
+	}
+}
+
+// cacheWrite stores value in memory for a TieredCache write with expiryNano, as
+// s3fifo.set takes it, forgetting any cached loader error for key. Returns
+// whether the caller must write value through to the store. With WriteBack,
+// the entry is marked dirty instead, or, once Shutdown has begun, nothing is
+// written and ErrClosed is returned. The write is registered as async work
+// meanwhile, so Shutdown syncs its dirty entry.
+func (c *TieredCache[K, V]) cacheWrite(key K, value V, expiryNano int64) (bool, error) {
+	if c.writeBack {
+		if !c.beginAsync() {
+			return false, ErrClosed
+		}
+		defer c.async.Done()
+	}
+	c.negative.del(key)
+	c.memory.set(key, value, expiryNano)
+	if !c.writeBack {
+		return true, nil
+	}
+	c.markDirty(key, value, expiryNano)
+	return false, nil
+}
+
+// markDirty marks key's entry for write-back after a write of value, writing
+// value back now if the entry was evicted before it could be marked.
+func (c *TieredCache[K, V]) markDirty(key K, value V, expiryNano int64) {
+	if dv := c.memory.markDirty(key, value, expiryNano); dv != nil {
+		c.writeEvicted(key, dv)
+	}
+}
+
+// writeEvicted writes back a value staged by eviction on its own goroutine.
+// Once Shutdown has finished its last Sync, the store is closing and the value
+// is dropped instead, counted as a failed store write. Called by s3fifo.unlock.
+func (c *TieredCache[K, V]) writeEvicted(key K, dv *dirtyValue[V]) {
+	if !c.beginWriteBack() {
+		c.memory.stats.recordStoreWrite(ErrClosed)
+		slog.Error("write-back persistence failed", "key", key, "error", ErrClosed)
+		for dv != nil {
+			dv = c.memory.written(key, dv)
+		}
+		return
+	}
+	go func() {
+		c.endAsync(key, c.writeDirty(c.abort, key, dv))
+	}()
+}
+
+// writeDirty writes dv to the store, then any value staged for key while it
+// ran, until none is left. Returns the error of the last write.
+func (c *TieredCache[K, V]) writeDirty(ctx context.Context, key K, dv *dirtyValue[V]) error {
+	var err error
+	for dv != nil {
+		storeCtx, cancel := context.WithTimeout(ctx, asyncTimeout)
+		err = c.Store.Set(storeCtx, key, dv.value, c.dirtyExpiry(dv))
+		cancel()
+		if err != nil {
+			c.memory.stats.recordStoreWrite(err)
+			slog.Error("write-back persistence failed", "key", key, "error", err)
+		}
+		dv = c.memory.written(key, dv)
+	}
+	return err
+}
+
+// dirtyExpiry returns the expiry to persist for a value staged for write-back.
+func (c *TieredCache[K, V]) dirtyExpiry(dv *dirtyValue[V]) time.Time {
+	if dv.expiry == 0 {
+		return time.Time{}
+	}
+	expiry := time.Unix(0, dv.expiry)
+	if dv.window != 0 {
+		return c.slidingStoreExpiry(expiry, time.Duration(dv.window))
+	}
+	return expiry
+}
+
+// Sync writes every dirty entry to the store, with WriteBack, and returns the
+// joined errors of the writes that failed. Their entries stay dirty if they are
+// still cached, so a later Sync retries them. Values already being written back
+// after eviction are left to that write. Without WriteBack, Sync does nothing.
+func (c *TieredCache[K, V]) Sync(ctx context.Context) error {
+	if !c.writeBack {
+		return nil
+	}
+	keys, vals := c.memory.stageDirty()
+	var errs []error
+	for i, k := range keys {
+		if err := c.writeDirty(ctx, k, vals[i]); err != nil {
+			c.memory.redirty(k)
+			errs = append(errs, fmt.Errorf("persistence store %v: %w", k, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,220 @@
+package fido
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newWriteBackCache(t *testing.T, store Store[string, int], opts ...Option) *TieredCache[string, int] {
+	t.Helper()
+	cache, err := NewTiered(store, append([]Option{WriteBack(), RecordStats()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestTieredCache_WriteBack_Sync(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	close(store.release)
+	cache := newWriteBackCache(t, store)
+
+	for v := 1; v <= 3; v++ {
+		if err := cache.Set(ctx, "a", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, found, _ := store.mockStore.Get(ctx, "a"); found { //nolint:errcheck // mock
+		t.Fatal("Set should not reach the store before Sync")
+	}
+
+	if err := cache.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.written, []int{3}) {
+		t.Errorf("store writes of a = %v; want [3], once", store.written)
+	}
+
+	if err := cache.Set(ctx, "a", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.written, []int{3, 4}) {
+		t.Errorf("store writes of a = %v; want [3 4] after Close", store.written)
+	}
+}
+
+func TestTieredCache_WriteBack_Evicted(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache := newWriteBackCache(t, store, Size(100))
+
+	const n = 1000
+	for i := range n {
+		if err := cache.Set(ctx, fmt.Sprintf("k%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Len() >= n {
+		t.Fatalf("Len = %d; want evictions below %d", cache.Len(), n)
+	}
+	// Evicted entries are readable, whether from the store or still on their way there.
+	for i := range n {
+		v, found, err := cache.Get(ctx, fmt.Sprintf("k%d", i))
+		if err != nil || !found || v != i {
+			t.Fatalf("Get(k%d) = %d, %v, %v; want %d", i, v, found, err, i)
+		}
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Len(ctx); got != n { //nolint:errcheck // mock
+		t.Errorf("stored %d entries; want %d", got, n)
+	}
+	if errs := cache.Stats().StoreWriteErrors; errs != 0 {
+		t.Errorf("StoreWriteErrors = %d; want 0", errs)
+	}
+}
+
+func TestTieredCache_WriteBack_Discard(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache := newWriteBackCache(t, store)
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := cache.Set(ctx, k, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found, _ := store.Get(ctx, "a"); found { //nolint:errcheck // mock
+		t.Error("Sync wrote back a deleted key")
+	}
+
+	if err := cache.Set(ctx, "d", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Len(ctx); got != 0 { //nolint:errcheck // mock
+		t.Errorf("stored %d entries after Flush; want 0", got)
+	}
+}
+
+func TestTieredCache_WriteBack_AfterShutdown(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	close(store.release)
+	cache := newWriteBackCache(t, store)
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]func() error{
+		"Set":     func() error { return cache.Set(ctx, "a", 2) },
+		"SetMany": func() error { return cache.SetMany(ctx, map[string]int{"a": 3}) },
+		"Replace": func() error { _, err := cache.Replace(ctx, "a", 4); return err },
+		"SetExpireAfterAccess": func() error {
+			return cache.SetExpireAfterAccess(ctx, "a", 5, time.Minute)
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Shutdown = %v; want ErrClosed", name, err)
+		}
+	}
+	if !slices.Equal(store.written, []int{1}) {
+		t.Errorf("store writes of a = %v; want [1], none after Shutdown", store.written)
+	}
+	if v, ok := cache.memory.get("a"); !ok || v != 1 {
+		t.Errorf("memory a = %d, %v; want 1 unchanged", v, ok)
+	}
+}
+
+func TestTieredCache_WriteBack_Load(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore[string, int]()
+	cache := newWriteBackCache(t, store, Shards(2))
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b"} {
+		if err := cache.Set(ctx, k, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaced dirty entries are written back; the loaded a is clean.
+	for _, k := range []string{"a", "b"} {
+		if v, _, found, _ := store.Get(ctx, k); !found || v != 2 { //nolint:errcheck // mock
+			t.Errorf("stored %s = %d, %v; want 2 written back before Load", k, v, found)
+		}
+	}
+	if v, ok := cache.memory.get("a"); !ok || v != 1 {
+		t.Errorf("memory a = %d, %v; want 1 from the snapshot", v, ok)
+	}
+}
+
+func TestTieredCache_WriteBack_SyncFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	close(store.release)
+	store.fails = 1
+	cache := newWriteBackCache(t, store)
+
+	if err := cache.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Sync(ctx); err == nil {
+		t.Fatal("Sync should report the failed write")
+	}
+	// The entry stays dirty, so the next Sync retries it.
+	if err := cache.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.written, []int{1}) {
+		t.Errorf("store writes of a = %v; want [1]", store.written)
+	}
+	if n := cache.Stats().StoreWriteErrors; n != 1 {
+		t.Errorf("StoreWriteErrors = %d; want 1", n)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,478 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what SetAsync does when the WriteBehind queue is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock waits for room in the queue, or until the caller's context is done.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop skips the store write and returns ErrQueueFull.
+	// The value is still cached in memory.
+	OverflowDrop
+	// OverflowSync writes to the store on the caller's goroutine, as SetTTL does.
+	OverflowSync
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDrop:
+		return "drop"
+	case OverflowSync:
+		return "sync"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrQueueFull is returned by SetAsync when the WriteBehind queue is full and
+// its policy is OverflowDrop. The value is cached in memory but not persisted.
+var ErrQueueFull = errors.New("write-behind queue full")
+
+// WriteBehindConfig configures the queue used by TieredCache.SetAsync.
+// Zero fields take their defaults.
+type WriteBehindConfig struct {
+	QueueSize    int            // keys waiting to be written, default 1024
+	Workers      int            // goroutines writing to the store, default 4
+	BatchSize    int            // writes per call to a BatchSetter store, default 64
+	MaxRetries   int            // retries of a failed write before it is dropped, default 3, negative for none
+	RetryBackoff time.Duration  // wait before the first retry, doubled for each later one, default 100ms
+	Overflow     OverflowPolicy // what SetAsync does when the queue is full, default OverflowBlock
+}
+
+// WriteBehind makes TieredCache.SetAsync queue store writes for a fixed pool of
+// workers instead of starting a goroutine per call. A write to a key that is
+// still queued replaces it, so only the last value is stored, and writes to one
+// key reach the store in order. Set, Delete and Flush drop the queued writes
+// they supersede and wait for those in flight. Stores implementing BatchSetter
+// receive queued writes in batches. Failed writes are retried with backoff, then
+// logged and counted in Stats.StoreWriteErrors. Shutdown and Close wait for
+// queued writes. Ignored by Cache.
+func WriteBehind(cfg WriteBehindConfig) Option {
+	return func(c *config) { c.writeBehind = &cfg }
+}
+
+// queuedWrite is a store write waiting in a writeQueue.
+type queuedWrite[V any] struct {
+	ctx    context.Context //nolint:containedctx // its values are kept for the store call
+	value  V
+	expiry time.Time
+}
+
+// writeQueue is the WriteBehind queue. Each key is in at most one of three
+// states: queued (in order, its write in pending), in flight (in inflight, a
+// newer write parked in pending until the current one finishes), or absent.
+// A nil *writeQueue is valid and means SetAsync starts a goroutine per write.
+type writeQueue[K comparable, V any] struct {
+	store   Store[K, V]
+	batcher BatchSetter[K, V] // nil unless the store implements it
+	stats   *cacheStats
+	cfg     WriteBehindConfig
+	wg      sync.WaitGroup
+	abort   context.Context //nolint:containedctx // cancelled when shutdown gives up on the queue
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	ready    *sync.Cond // signalled when order grows, or inflight shrinks after close
+	room     *sync.Cond // broadcast when order shrinks or the queue closes
+	idle     *sync.Cond // broadcast when inflight shrinks
+	pending  map[K]queuedWrite[V]
+	inflight map[K]struct{}
+	order    []K // queued keys, oldest first, at most cfg.QueueSize
+	closed   bool
+	failed   []error // writes that failed after closing, reported by shutdown
+}
+
+// newWriteQueue applies cfg's defaults and starts the workers.
+func newWriteQueue[K comparable, V any](store Store[K, V], stats *cacheStats, cfg WriteBehindConfig) *writeQueue[K, V] {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 64
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	q := &writeQueue[K, V]{
+		store:    store,
+		stats:    stats,
+		cfg:      cfg,
+		pending:  make(map[K]queuedWrite[V]),
+		inflight: make(map[K]struct{}),
+	}
+	if b, ok := store.(BatchSetter[K, V]); ok {
+		q.batcher = b
+	} else {
+		q.cfg.BatchSize = 1
+	}
+	q.ready = sync.NewCond(&q.mu)
+	q.room = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+	q.abort, q.cancel = context.WithCancel(context.Background())
+	q.wg.Add(cfg.Workers)
+	for range cfg.Workers {
+		go q.work()
+	}
+	return q
+}
+
+// add queues w for key, applying the overflow policy when the queue is full.
+// Returns ErrClosed once shutdown has begun.
+func (q *writeQueue[K, V]) add(ctx context.Context, key K, w queuedWrite[V]) error {
+	direct, err := q.admit(ctx, key, w)
+	if !direct || err != nil {
+		return err
+	}
+	err = q.store.Set(ctx, key, w.value, w.expiry)
+	q.finish([]K{key})
+	if err != nil {
+		q.stats.recordStoreWrite(err)
+		return fmt.Errorf("persistence store failed: %w", err)
+	}
+	return nil
+}
+
+// admit queues w, or reports that the caller must write it directly. A key
+// written directly is marked in flight, so later writes wait for it.
+func (q *writeQueue[K, V]) admit(ctx context.Context, key K, w queuedWrite[V]) (direct bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var stop func() bool
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for {
+		_, queued := q.pending[key]
+		_, writing := q.inflight[key]
+		switch {
+		case queued || writing:
+			// Replace the queued write, or park behind the one in flight
+			// until finish queues the key again.
+			q.pending[key] = w
+			return false, nil
+		case q.closed:
+			return false, ErrClosed
+		case len(q.order) < q.cfg.QueueSize:
+			q.pending[key] = w
+			q.order = append(q.order, key)
+			q.ready.Signal()
+			return false, nil
+		}
+
+		switch q.cfg.Overflow {
+		case OverflowDrop:
+			return false, ErrQueueFull
+		case OverflowSync:
+			q.inflight[key] = struct{}{}
+			return true, nil
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if stop == nil {
+			stop = context.AfterFunc(ctx, func() {
+				q.mu.Lock()
+				q.room.Broadcast()
+				q.mu.Unlock()
+			})
+		}
+		q.room.Wait()
+	}
+}
+
+// work writes batches until the queue is closed and empty.
+func (q *writeQueue[K, V]) work() {
+	defer q.wg.Done()
+	for {
+		keys, writes := q.next()
+		if keys == nil {
+			return
+		}
+		q.write(keys, writes)
+		q.finish(keys)
+	}
+}
+
+// next takes the oldest queued keys, up to a batch, and marks them in flight.
+// Returns nil once the queue is closed and nothing is queued or in flight,
+// or once shutdown has given up on it.
+func (q *writeQueue[K, V]) next() ([]K, []queuedWrite[V]) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.order) == 0 || q.abort.Err() != nil {
+		if q.abort.Err() != nil || q.closed && len(q.inflight) == 0 {
+			return nil, nil
+		}
+		q.ready.Wait()
+	}
+	n := min(len(q.order), q.cfg.BatchSize)
+	keys := make([]K, n)
+	copy(keys, q.order)
+	clear(q.order[:n])
+	q.order = q.order[n:]
+	writes := make([]queuedWrite[V], n)
+	for i, k := range keys {
+		writes[i] = q.pending[k]
+		delete(q.pending, k)
+		q.inflight[k] = struct{}{}
+	}
+	q.room.Broadcast()
+	return keys, writes
+}
+
+// finish clears the in-flight mark of keys, queueing any write parked behind them.
+// Parked writes may briefly exceed QueueSize, by at most the writes in flight.
+// A nil queue has nothing to finish.
+func (q *writeQueue[K, V]) finish(keys []K) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, k := range keys {
+		delete(q.inflight, k)
+		if _, ok := q.pending[k]; ok {
+			q.order = append(q.order, k)
+			q.ready.Signal()
+		}
+	}
+	q.idle.Broadcast()
+	if q.closed {
+		q.ready.Broadcast() // idle workers may now exit
+	}
+}
+
+// hold takes keys from the workers for a store write or delete made by the
+// caller, so that no queued write reaches the store after it. It waits until
+// none of keys is in flight, then takes them all at once: their queued writes
+// are dropped, as the caller's call supersedes them, and they are marked in
+// flight, so SetAsync parks behind them until the caller calls finish. Taking
+// all or none keeps callers with overlapping keys from holding each other up.
+// Returns ctx.Err(), holding nothing, if ctx is done first. A nil queue holds
+// nothing.
+func (q *writeQueue[K, V]) hold(ctx context.Context, keys []K) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var stop func() bool
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for q.anyInflight(keys) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop == nil {
+			stop = context.AfterFunc(ctx, func() {
+				q.mu.Lock()
+				q.idle.Broadcast()
+				q.mu.Unlock()
+			})
+		}
+		q.idle.Wait()
+	}
+	for _, k := range keys {
+		q.unqueue(k)
+		q.inflight[k] = struct{}{}
+	}
+	return nil
+}
+
+// anyInflight reports whether a write to any of keys is in flight. Must hold mu.
+func (q *writeQueue[K, V]) anyInflight(keys []K) bool {
+	for _, k := range keys {
+		if _, writing := q.inflight[k]; writing {
+			return true
+		}
+	}
+	return false
+}
+
+// unqueue drops key's queued write, if any. Must hold mu, and key must not be in flight.
+func (q *writeQueue[K, V]) unqueue(key K) {
+	if _, ok := q.pending[key]; !ok {
+		return
+	}
+	delete(q.pending, key)
+	if i := slices.Index(q.order, key); i >= 0 {
+		q.order = slices.Delete(q.order, i, i+1)
+		q.room.Broadcast()
+	}
+}
+
+// queued returns the keys with a write queued or in flight that match.
+// A nil queue has none.
+func (q *writeQueue[K, V]) queued(match func(K) bool) []K {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var keys []K
+	for k := range q.pending {
+		if match(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range q.inflight {
+		if _, ok := q.pending[k]; !ok && match(k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// drop forgets every queued write, for Flush, and waits until the writes in
+// flight have finished or ctx is done. A nil queue has nothing to drop.
+func (q *writeQueue[K, V]) drop(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	clear(q.pending)
+	q.order = nil
+	q.room.Broadcast()
+	defer context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.idle.Broadcast()
+		q.mu.Unlock()
+	})()
+	for len(q.inflight) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.idle.Wait()
+	}
+	return nil
+}
+
+// write stores a batch, retrying with backoff. The batch is written whole, so
+// a failure retries every write in it.
+func (q *writeQueue[K, V]) write(keys []K, writes []queuedWrite[V]) {
+	backoff := q.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := q.storeBatch(keys, writes)
+		if err == nil {
+			return
+		}
+		if attempt >= q.cfg.MaxRetries || !q.sleep(backoff) {
+			q.fail(keys, err)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// sleep waits for d, returning false if shutdown gives up on the queue first.
+func (q *writeQueue[K, V]) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-q.abort.Done():
+		return false
+	}
+}
+
+// fail logs and counts writes that will not be retried, keeping them for
+// shutdown to report if the queue is closing.
+func (q *writeQueue[K, V]) fail(keys []K, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, k := range keys {
+		q.stats.recordStoreWrite(err)
+		slog.Error("async persistence failed", "key", k, "error", err)
+		if q.closed {
+			q.failed = append(q.failed, fmt.Errorf("persistence store %v: %w", k, err))
+		}
+	}
+}
+
+// storeBatch makes one store call for the batch, with the values of the first
+// writer's context but not its cancellation. Shutdown giving up cancels it.
+func (q *writeQueue[K, V]) storeBatch(keys []K, writes []queuedWrite[V]) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(writes[0].ctx), asyncTimeout)
+	defer cancel()
+	defer context.AfterFunc(q.abort, cancel)()
+	if len(keys) == 1 {
+		return q.store.Set(ctx, keys[0], writes[0].value, writes[0].expiry)
+	}
+	vals := make(map[K]V, len(keys))
+	expiries := make(map[K]time.Time, len(keys))
+	for i, k := range keys {
+		vals[k] = writes[i].value
+		if !writes[i].expiry.IsZero() {
+			expiries[k] = writes[i].expiry
+		}
+	}
+	return q.batcher.SetMany(ctx, vals, expiries)
+}
+
+// shutdown stops accepting writes and waits for the queued ones until ctx is
+// done. Then it cancels the writes in flight, waits for the workers to return,
+// and gives up on the rest. Returns an error for each write that failed or was
+// given up on.
+func (q *writeQueue[K, V]) shutdown(ctx context.Context) []error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	q.closed = true
+	q.ready.Broadcast()
+	q.room.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		q.mu.Lock()
+		q.cancel()
+		q.ready.Broadcast()
+		q.mu.Unlock()
+		<-done
+	}
+	q.cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for k := range q.pending {
+		q.failed = append(q.failed, fmt.Errorf("persistence store %v: %w", k, context.Cause(ctx)))
+	}
+	clear(q.pending)
+	q.order = nil
+	failed := q.failed
+	q.failed = nil
+	return failed
+}
@@ -0,0 +1,395 @@
+package fido
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateStore is a mockStore whose writes of key "a" wait until release is closed
+// or their context is done,
+// recording the values written to "a" and the size of each SetMany batch.
+type gateStore struct {
+	*mockStore[string, int]
+	started chan struct{}
+	release chan struct{}
+
+	mu      sync.Mutex
+	written []int
+	batches []int
+	fails   int // Set calls left to fail
+}
+
+func newGateStore() *gateStore {
+	return &gateStore{
+		mockStore: newMockStore[string, int](),
+		started:   make(chan struct{}, 16),
+		release:   make(chan struct{}),
+	}
+}
+
+func (s *gateStore) Set(ctx context.Context, key string, value int, expiry time.Time) error {
+	if key == "a" {
+		s.started <- struct{}{}
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	if s.fails > 0 {
+		s.fails--
+		s.mu.Unlock()
+		return errors.New("set failed")
+	}
+	if key == "a" {
+		s.written = append(s.written, value)
+	}
+	s.mu.Unlock()
+	return s.mockStore.Set(ctx, key, value, expiry)
+}
+
+// batchSetStore adds a BatchSetter to gateStore.
+type batchSetStore struct {
+	*gateStore
+}
+
+func (s batchSetStore) SetMany(ctx context.Context, vals map[string]int, expiries map[string]time.Time) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(vals))
+	s.mu.Unlock()
+	for k, v := range vals {
+		if err := s.mockStore.Set(ctx, k, v, expiries[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newWriteBehindCache(t *testing.T, store Store[string, int], cfg WriteBehindConfig) *TieredCache[string, int] {
+	t.Helper()
+	cache, err := NewTiered[string, int](store, WriteBehind(cfg), RecordStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestTieredCache_WriteBehind_Coalesces(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 2})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	// Parked behind the write in flight; only the last reaches the store, after it.
+	for v := 2; v <= 5; v++ {
+		if err := cache.SetAsync(ctx, "a", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(store.release)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(store.written, []int{1, 5}) {
+		t.Errorf("store writes of a = %v; want [1 5]", store.written)
+	}
+	if v, ok := cache.memory.get("a"); !ok || v != 5 {
+		t.Errorf("memory a = %d, %v; want 5", v, ok)
+	}
+}
+
+func TestTieredCache_WriteBehind_DeleteAfterSetAsync(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	if err := cache.SetAsync(ctx, "b", 2); err != nil { // queued behind a
+		t.Fatal(err)
+	}
+	if err := cache.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	deleted := make(chan error, 1)
+	go func() { deleted <- cache.Delete(ctx, "a") }() // waits for the write in flight
+	select {
+	case err := <-deleted:
+		t.Fatalf("Delete(a) = %v before the write in flight finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(store.release)
+	if err := <-deleted; err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b"} {
+		if _, _, found, _ := store.mockStore.Get(ctx, k); found { //nolint:errcheck // mock
+			t.Errorf("%s stored after Delete", k)
+		}
+	}
+}
+
+func TestTieredCache_WriteBehind_SetAfterSetAsync(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	if err := cache.SetAsync(ctx, "b", 1); err != nil { // queued behind a
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "b", 2); err != nil {
+		t.Fatal(err)
+	}
+	set := make(chan error, 1)
+	go func() { set <- cache.Set(ctx, "a", 2) }() // waits for the write in flight
+	<-store.started
+	close(store.release)
+	if err := <-set; err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(store.written, []int{1, 2}) {
+		t.Errorf("store writes of a = %v; want [1 2]", store.written)
+	}
+	if v, _, _, _ := store.mockStore.Get(ctx, "b"); v != 2 { //nolint:errcheck // mock
+		t.Errorf("stored b = %d; want 2 from the later Set", v)
+	}
+}
+
+func TestWriteQueue_HoldOverlapping(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	for _, k := range []string{"b", "c"} {
+		if err := cache.SetAsync(ctx, k, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Both wait for a, in flight, and share b and c.
+	done := make(chan struct{}, 2)
+	for _, keys := range [][]string{{"b", "c", "a"}, {"a", "c", "b"}} {
+		go func() {
+			if err := cache.queue.hold(ctx, keys); err != nil {
+				t.Error(err)
+			}
+			cache.queue.finish(keys)
+			done <- struct{}{}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("overlapping holds deadlocked")
+		}
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// scanGateStore adds a PrefixScanner to gateStore.
+type scanGateStore struct {
+	*gateStore
+}
+
+func (s scanGateStore) Keys(ctx context.Context, prefix string) iter.Seq[string] {
+	return (&scanStore[int]{mockStore: s.mockStore}).Keys(ctx, prefix)
+}
+
+func (s scanGateStore) Range(ctx context.Context, prefix string) iter.Seq2[string, int] {
+	return (&scanStore[int]{mockStore: s.mockStore}).Range(ctx, prefix)
+}
+
+func TestTieredCache_WriteBehind_DeletePrefixQueued(t *testing.T) {
+	ctx := context.Background()
+	store := scanGateStore{newGateStore()}
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	if err := cache.SetAsync(ctx, "user:1", 1); err != nil { // queued, not yet stored
+		t.Fatal(err)
+	}
+	if _, err := cache.DeletePrefix(ctx, "user:"); err != nil {
+		t.Fatal(err)
+	}
+	close(store.release)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found, _ := store.mockStore.Get(ctx, "user:1"); found { //nolint:errcheck // mock
+		t.Error("queued write stored user:1 after DeletePrefix")
+	}
+}
+
+func TestTieredCache_WriteBehind_FlushDropsQueued(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1})
+
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	if err := cache.SetAsync(ctx, "b", 2); err != nil {
+		t.Fatal(err)
+	}
+	flushed := make(chan error, 1)
+	go func() {
+		_, err := cache.Flush(ctx)
+		flushed <- err
+	}()
+	close(store.release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := store.mockStore.Len(ctx); n != 0 { //nolint:errcheck // mock
+		t.Errorf("store holds %d entries after Flush; want 0", n)
+	}
+}
+
+func TestTieredCache_WriteBehind_Overflow(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		wantErr error
+		stored  bool // whether c reaches the store before release
+	}{
+		{OverflowDrop, ErrQueueFull, false},
+		{OverflowSync, nil, true},
+		{OverflowBlock, context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.policy), func(t *testing.T) {
+			store := newGateStore()
+			cache := newWriteBehindCache(t, store, WriteBehindConfig{QueueSize: 1, Workers: 1, Overflow: tt.policy})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			if err := cache.SetAsync(ctx, "a", 1); err != nil {
+				t.Fatal(err)
+			}
+			<-store.started
+			if err := cache.SetAsync(ctx, "b", 2); err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.SetAsync(ctx, "b", 3); err != nil {
+				t.Fatalf("coalesced write to a full queue: %v", err)
+			}
+
+			err := cache.SetAsync(ctx, "c", 4)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetAsync on a full queue = %v; want %v", err, tt.wantErr)
+			}
+			if _, ok := cache.memory.get("c"); !ok {
+				t.Error("value should be cached in memory whatever the policy")
+			}
+			if _, _, found, _ := store.mockStore.Get(ctx, "c"); found != tt.stored { //nolint:errcheck // mock
+				t.Errorf("c stored = %v; want %v", found, tt.stored)
+			}
+
+			close(store.release)
+			if err := cache.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if v, _, _, _ := store.mockStore.Get(context.Background(), "b"); v != 3 { //nolint:errcheck // mock
+				t.Errorf("stored b = %d; want 3", v)
+			}
+		})
+	}
+}
+
+func TestTieredCache_WriteBehind_Batches(t *testing.T) {
+	ctx := context.Background()
+	store := batchSetStore{newGateStore()}
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{Workers: 1, BatchSize: 4})
+
+	if err := cache.SetAsync(ctx, "a", 0); err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+	for i := range 10 {
+		if err := cache.SetAsync(ctx, fmt.Sprintf("k%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(store.release)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(store.batches, []int{4, 4, 2}) {
+		t.Errorf("SetMany batch sizes = %v; want [4 4 2]", store.batches)
+	}
+	if n, _ := store.Len(ctx); n != 11 { //nolint:errcheck // mock
+		t.Errorf("stored %d entries; want 11", n)
+	}
+}
+
+func TestTieredCache_WriteBehind_Retries(t *testing.T) {
+	ctx := context.Background()
+	store := newGateStore()
+	close(store.release)
+	store.fails = 2
+	cache := newWriteBehindCache(t, store, WriteBehindConfig{RetryBackoff: time.Millisecond})
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.written, []int{1}) {
+		t.Errorf("store writes of a = %v; want [1] after retries", store.written)
+	}
+	if n := cache.Stats().StoreWriteErrors; n != 0 {
+		t.Errorf("StoreWriteErrors = %d; want 0 for a write that succeeded on retry", n)
+	}
+
+	store = newGateStore()
+	close(store.release)
+	store.fails = 1
+	cache = newWriteBehindCache(t, store, WriteBehindConfig{MaxRetries: -1})
+	if err := cache.SetAsync(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	cache.Close() //nolint:errcheck // reports the failed write if it fails after Close begins
+	if n := cache.Stats().StoreWriteErrors; n != 1 {
+		t.Errorf("StoreWriteErrors = %d; want 1 for a write that was not retried", n)
+	}
+}